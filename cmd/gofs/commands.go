@@ -0,0 +1,426 @@
+package main
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/renatopp/go-fs"
+)
+
+// entry is the JSON representation of a listed file or directory.
+type entry struct {
+	Path  string `json:"path"`
+	IsDir bool   `json:"dir"`
+	Size  int64  `json:"size"`
+}
+
+func runLs(args []string) error {
+	var asJson, recursive bool
+	var pattern string
+	flags := newFlags("ls", &asJson)
+	flags.BoolVar(&recursive, "r", false, "list recursively")
+	flags.StringVar(&pattern, "g", "", "list only entries matching the glob pattern")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return errUsage
+	}
+	dir := "."
+	if flags.NArg() == 1 {
+		dir = flags.Arg(0)
+	}
+
+	names, err := list(dir, pattern, recursive)
+	if err != nil {
+		return err
+	}
+
+	if !asJson {
+		for _, name := range names {
+			if fs.IsDir(filepath.Join(dir, name)) {
+				name += fs.PathSeparator
+			}
+			fmt.Fprintln(stdout, name)
+		}
+		return nil
+	}
+
+	entries := make([]entry, len(names))
+	for i, name := range names {
+		full := filepath.Join(dir, name)
+		entries[i] = entry{Path: fs.ToSlashPath(name), IsDir: fs.IsDir(full)}
+		if !entries[i].IsDir {
+			entries[i].Size = fs.ForceSize(full)
+		}
+	}
+	return printJson(entries)
+}
+
+// list returns the sorted entries of a directory, filtered by the glob pattern
+// if one is given.
+func list(dir, pattern string, recursive bool) ([]string, error) {
+	var names []string
+	var err error
+	switch {
+	case pattern != "":
+		if !fs.IsPatternValid(pattern) {
+			return nil, fmt.Errorf("%w: invalid pattern %q", errUsage, pattern)
+		}
+		names, err = fs.Glob(dir, pattern)
+	case recursive:
+		names, err = fs.ListRecursive(dir)
+	default:
+		names, err = fs.List(dir)
+	}
+	slices.Sort(names)
+	return names, err
+}
+
+// node is the JSON representation of a directory tree.
+type node struct {
+	Name     string  `json:"name"`
+	IsDir    bool    `json:"dir"`
+	Children []*node `json:"children,omitempty"`
+}
+
+func runTree(args []string) error {
+	var asJson bool
+	var pattern string
+	flags := newFlags("tree", &asJson)
+	flags.StringVar(&pattern, "g", "", "show only entries matching the glob pattern")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return errUsage
+	}
+	dir := "."
+	if flags.NArg() == 1 {
+		dir = flags.Arg(0)
+	}
+
+	names, err := list(dir, pattern, true)
+	if err != nil {
+		return err
+	}
+
+	root := &node{Name: dir, IsDir: true}
+	for _, name := range names {
+		current := root
+		parts := fs.SplitPath(name)
+		for i, part := range parts {
+			j := slices.IndexFunc(current.Children, func(n *node) bool { return n.Name == part })
+			if j < 0 {
+				child := &node{Name: part, IsDir: i < len(parts)-1}
+				if !child.IsDir {
+					child.IsDir = fs.IsDir(filepath.Join(dir, name))
+				}
+				current.Children = append(current.Children, child)
+				j = len(current.Children) - 1
+			}
+			current = current.Children[j]
+		}
+	}
+
+	if asJson {
+		return printJson(root)
+	}
+	fmt.Fprintln(stdout, root.Name)
+	printTree(root, "")
+	return nil
+}
+
+// printTree prints the children of a node with box-drawing characters.
+func printTree(n *node, prefix string) {
+	for i, child := range n.Children {
+		branch, next := "├── ", "│   "
+		if i == len(n.Children)-1 {
+			branch, next = "└── ", "    "
+		}
+		name := child.Name
+		if child.IsDir {
+			name += "/"
+		}
+		fmt.Fprintln(stdout, prefix+branch+name)
+		printTree(child, prefix+next)
+	}
+}
+
+// result is the JSON representation of the outcome of an operation on a path.
+type result struct {
+	Path  string `json:"path"`
+	Dest  string `json:"dest,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+func runCp(args []string) error {
+	return runTransfer("cp", args, fs.Copy)
+}
+
+func runMv(args []string) error {
+	return runTransfer("mv", args, fs.Move)
+}
+
+// runTransfer runs a command taking a source and a destination.
+func runTransfer(name string, args []string, fn func(src, dst string) error) error {
+	var asJson bool
+	flags := newFlags(name, &asJson)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errUsage
+	}
+	src, dst := flags.Arg(0), flags.Arg(1)
+	if !fs.Exists(src) {
+		return fmt.Errorf("%s: %w", src, fs.ErrNotExist)
+	}
+
+	err := fn(src, dst)
+	if !asJson {
+		return err
+	}
+	res := result{Path: src, Dest: dst}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	if jsonErr := printJson(res); jsonErr != nil {
+		return jsonErr
+	}
+	if err != nil {
+		return errSilent
+	}
+	return nil
+}
+
+func runRm(args []string) error {
+	var asJson bool
+	flags := newFlags("rm", &asJson)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errUsage
+	}
+
+	results := []result{}
+	failed := false
+	for _, p := range flags.Args() {
+		res := result{Path: p}
+		if err := fs.Remove(p); err != nil {
+			failed = true
+			res.Error = err.Error()
+			if !asJson {
+				fmt.Fprintf(stderr, "gofs rm: %s\n", err)
+			}
+		}
+		results = append(results, res)
+	}
+
+	if asJson {
+		if err := printJson(results); err != nil {
+			return err
+		}
+	}
+	if failed {
+		return errSilent
+	}
+	return nil
+}
+
+// newHash returns the hash constructor for the given algorithm name.
+func newHash(algorithm string) (func() hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case "md5":
+		return md5.New, nil
+	case "sha1":
+		return sha1.New, nil
+	case "sha256":
+		return sha256.New, nil
+	}
+	return nil, fmt.Errorf("%w: unknown hash algorithm %q", errUsage, algorithm)
+}
+
+// checksum is the JSON representation of the hash of a path.
+type checksum struct {
+	Path     string `json:"path"`
+	Hash     string `json:"hash,omitempty"`
+	Expected string `json:"expected,omitempty"`
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+}
+
+func runHash(args []string) error {
+	var asJson bool
+	var algorithm string
+	flags := newFlags("hash", &asJson)
+	flags.StringVar(&algorithm, "a", "sha256", "hash algorithm: md5, sha1 or sha256")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errUsage
+	}
+	h, err := newHash(algorithm)
+	if err != nil {
+		return err
+	}
+
+	sums := []checksum{}
+	failed := false
+	for _, p := range flags.Args() {
+		sum := checksum{Path: p}
+		sum.Hash, err = fs.Hash(p, h())
+		sum.OK = err == nil
+		if err != nil {
+			failed = true
+			sum.Error = err.Error()
+		}
+		sums = append(sums, sum)
+
+		if asJson {
+			continue
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "gofs hash: %s\n", err)
+		} else {
+			fmt.Fprintf(stdout, "%s  %s\n", sum.Hash, p)
+		}
+	}
+
+	if asJson {
+		if err := printJson(sums); err != nil {
+			return err
+		}
+	}
+	if failed {
+		return errSilent
+	}
+	return nil
+}
+
+func runVerify(args []string) error {
+	var asJson bool
+	var algorithm string
+	flags := newFlags("verify", &asJson)
+	flags.StringVar(&algorithm, "a", "sha256", "hash algorithm: md5, sha1 or sha256")
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errUsage
+	}
+	h, err := newHash(algorithm)
+	if err != nil {
+		return err
+	}
+
+	// Paths in the checksum file are relative to the file itself, in the
+	// "<hash>  <path>" format used by the hash command and sha256sum.
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	base := filepath.Dir(flags.Arg(0))
+
+	sums := []checksum{}
+	failed := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		expected, p, ok := strings.Cut(line, " ")
+		if !ok {
+			return fmt.Errorf("malformed line %q", line)
+		}
+		p = strings.TrimPrefix(strings.TrimLeft(p, " "), "*")
+
+		sum := checksum{Path: p, Expected: strings.ToLower(expected)}
+		sum.Hash, err = fs.Hash(filepath.Join(base, p), h())
+		sum.OK = err == nil && sum.Hash == sum.Expected
+		if err != nil {
+			sum.Error = err.Error()
+		}
+		failed = failed || !sum.OK
+		sums = append(sums, sum)
+
+		if !asJson {
+			status := "OK"
+			if !sum.OK {
+				status = "FAILED"
+			}
+			fmt.Fprintf(stdout, "%s: %s\n", p, status)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if asJson {
+		if err := printJson(sums); err != nil {
+			return err
+		}
+	}
+	if failed {
+		return errSilent
+	}
+	return nil
+}
+
+// diskUsage is the JSON representation of the size of a path.
+type diskUsage struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Error string `json:"error,omitempty"`
+}
+
+func runDu(args []string) error {
+	var asJson bool
+	flags := newFlags("du", &asJson)
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	sizes := []diskUsage{}
+	failed := false
+	for _, p := range paths {
+		size, err := fs.Size(p)
+		u := diskUsage{Path: p, Size: size}
+		if err != nil {
+			failed = true
+			u.Error = err.Error()
+			if !asJson {
+				fmt.Fprintf(stderr, "gofs du: %s\n", err)
+			}
+		} else if !asJson {
+			fmt.Fprintf(stdout, "%d\t%s\n", size, p)
+		}
+		sizes = append(sizes, u)
+	}
+
+	if asJson {
+		if err := printJson(sizes); err != nil {
+			return err
+		}
+	}
+	if failed {
+		return errSilent
+	}
+	return nil
+}
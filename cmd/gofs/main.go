@@ -0,0 +1,141 @@
+// Command gofs exposes the functions of the fs package on the command line, so
+// scripts and Go programs share the same behavior.
+//
+// Usage:
+//
+//	gofs <command> [flags] [arguments]
+//
+// The commands are:
+//
+//	ls      list the entries of a directory
+//	tree    print a directory tree
+//	cp      copy files or directories
+//	mv      move files or directories
+//	rm      remove files or directories
+//	hash    print the hash of files or directories
+//	verify  check the hashes listed in a checksum file
+//	du      print the size of files or directories
+//	watch   rerun a command whenever files change
+//
+// Most commands accept -json to print machine-readable output. The exit code
+// is 0 on success, 1 when an operation fails (or a verification does not
+// match) and 2 on invalid usage.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	exitOK    = 0
+	exitFail  = 1
+	exitUsage = 2
+)
+
+// errUsage is returned by commands when their arguments are invalid.
+var errUsage = errors.New("invalid usage")
+
+// command is a subcommand of gofs.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"ls", "ls [-r] [-g pattern] [-json] [dir]", runLs},
+	{"tree", "tree [-g pattern] [-json] [dir]", runTree},
+	{"cp", "cp [-json] <src> <dst>", runCp},
+	{"mv", "mv [-json] <src> <dst>", runMv},
+	{"rm", "rm [-json] <path>...", runRm},
+	{"hash", "hash [-a md5|sha1|sha256] [-json] <path>...", runHash},
+	{"verify", "verify [-a md5|sha1|sha256] [-json] <checksum-file>", runVerify},
+	{"du", "du [-json] <path>...", runDu},
+	{"watch", "watch [-g pattern] [-d debounce] [-i] [-json] [path] -- <command> [args...]", runWatch},
+}
+
+// stdout and stderr are where commands write their output.
+var (
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+// run executes the command line and returns the exit code.
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage()
+		return exitUsage
+	}
+
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(args[1:])
+		switch {
+		case err == nil:
+			return exitOK
+		case errors.Is(err, errUsage), errors.Is(err, flag.ErrHelp):
+			if err != errUsage && !errors.Is(err, flag.ErrHelp) {
+				fmt.Fprintf(stderr, "gofs %s: %s\n", cmd.name, err)
+			}
+			fmt.Fprintf(stderr, "usage: gofs %s\n", cmd.usage)
+			return exitUsage
+		case errors.Is(err, errSilent):
+			return exitFail
+		default:
+			fmt.Fprintf(stderr, "gofs %s: %s\n", cmd.name, err)
+			return exitFail
+		}
+	}
+
+	fmt.Fprintf(stderr, "gofs: unknown command %q\n", args[0])
+	usage()
+	return exitUsage
+}
+
+// errSilent is returned by commands that already reported their failure.
+var errSilent = errors.New("failed")
+
+func usage() {
+	fmt.Fprintln(stderr, "usage: gofs <command> [flags] [arguments]")
+	fmt.Fprintln(stderr)
+	fmt.Fprintln(stderr, "commands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(stderr, "  %s\n", cmd.usage)
+	}
+}
+
+// newFlags creates the flag set of a command, with the common -json flag.
+func newFlags(name string, asJson *bool) *flag.FlagSet {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.BoolVar(asJson, "json", false, "print machine-readable JSON output")
+	return flags
+}
+
+// parseFlags parses the arguments of a command, reporting invalid flags as
+// usage errors.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	return err
+}
+
+// printJson writes v to the standard output as indented JSON.
+func printJson(v any) error {
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// runGofs runs the command line, returning the exit code and the output.
+func runGofs(args ...string) (int, string, string) {
+	var out, errOut bytes.Buffer
+	stdout, stderr = &out, &errOut
+	defer func() {
+		stdout, stderr = os.Stdout, os.Stderr
+	}()
+	code := run(args)
+	return code, out.String(), errOut.String()
+}
+
+// sha256Hex returns the SHA256 of data as a hexadecimal string.
+func sha256Hex(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+// maskErrors replaces the non-empty "error" fields of decoded JSON with "*",
+// as messages depend on the platform.
+func maskErrors(v any) {
+	switch v := v.(type) {
+	case []any:
+		for _, item := range v {
+			maskErrors(item)
+		}
+	case map[string]any:
+		if msg, ok := v["error"].(string); ok && msg != "" {
+			v["error"] = "*"
+		}
+		for _, item := range v {
+			maskErrors(item)
+		}
+	}
+}
+
+// quote returns s as a JSON string.
+func quote(s string) string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{"a": "a", "sub/b": "bb"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := filepath.Join(dir, "a")
+	sub := filepath.Join(dir, "sub")
+	missing := filepath.Join(dir, "missing")
+	good := filepath.Join(dir, "good.sha256")
+	bad := filepath.Join(dir, "bad.sha256")
+	os.WriteFile(good, []byte(sha256Hex("a")+"  a\n"), 0644)
+	os.WriteFile(bad, []byte(sha256Hex("b")+"  a\n"), 0644)
+
+	tests := []struct {
+		name   string
+		args   []string
+		code   int
+		stdout string // Expected output, if not empty
+		json   string // Expected JSON output, compared structurally, if not empty
+	}{
+		{"no command", nil, exitUsage, "", ""},
+		{"unknown command", []string{"nope"}, exitUsage, "", ""},
+
+		{"ls", []string{"ls", sub}, exitOK, "b\n", ""},
+		{"ls json", []string{"ls", "-json", dir}, exitOK, "", `[
+			{"path": "a", "dir": false, "size": 1},
+			{"path": "bad.sha256", "dir": false, "size": 68},
+			{"path": "good.sha256", "dir": false, "size": 68},
+			{"path": "sub", "dir": true, "size": 0}
+		]`},
+		{"ls recursive glob", []string{"ls", "-r", "-g", "**/b", "-json", dir}, exitOK, "", `[
+			{"path": "sub/b", "dir": false, "size": 2}
+		]`},
+		{"ls missing", []string{"ls", missing}, exitFail, "", ""},
+		{"ls too many arguments", []string{"ls", dir, dir}, exitUsage, "", ""},
+		{"ls unknown flag", []string{"ls", "-x", dir}, exitUsage, "", ""},
+		{"ls invalid pattern", []string{"ls", "-g", "[", dir}, exitUsage, "", ""},
+
+		{"tree", []string{"tree", sub}, exitOK, sub + "\n└── b\n", ""},
+		{"tree json", []string{"tree", "-g", "sub/**", "-json", dir}, exitOK, "", `{
+			"name": ` + quote(dir) + `, "dir": true, "children": [
+				{"name": "sub", "dir": true, "children": [{"name": "b", "dir": false}]}
+			]
+		}`},
+		{"tree missing", []string{"tree", missing}, exitFail, "", ""},
+		{"tree too many arguments", []string{"tree", dir, dir}, exitUsage, "", ""},
+
+		{"hash", []string{"hash", a}, exitOK, sha256Hex("a") + "  " + a + "\n", ""},
+		{"hash json", []string{"hash", "-a", "sha256", "-json", a, missing}, exitFail, "", `[
+			{"path": ` + quote(a) + `, "hash": ` + quote(sha256Hex("a")) + `, "ok": true},
+			{"path": ` + quote(missing) + `, "ok": false, "error": "*"}
+		]`},
+		{"hash missing", []string{"hash", missing}, exitFail, "", ""},
+		{"hash unknown algorithm", []string{"hash", "-a", "crc", a}, exitUsage, "", ""},
+		{"hash no arguments", []string{"hash"}, exitUsage, "", ""},
+
+		{"verify", []string{"verify", good}, exitOK, "a: OK\n", ""},
+		{"verify mismatch", []string{"verify", bad}, exitFail, "a: FAILED\n", ""},
+		{"verify json", []string{"verify", "-json", bad}, exitFail, "", `[
+			{"path": "a", "hash": ` + quote(sha256Hex("a")) + `, "expected": ` + quote(sha256Hex("b")) + `, "ok": false}
+		]`},
+		{"verify missing", []string{"verify", missing}, exitFail, "", ""},
+		{"verify no arguments", []string{"verify"}, exitUsage, "", ""},
+
+		{"du", []string{"du", sub}, exitOK, "2\t" + sub + "\n", ""},
+		{"du json", []string{"du", "-json", a, sub}, exitOK, "", `[
+			{"path": ` + quote(a) + `, "size": 1},
+			{"path": ` + quote(sub) + `, "size": 2}
+		]`},
+		{"du missing", []string{"du", missing}, exitFail, "", ""},
+
+		{"rm missing", []string{"rm", missing}, exitOK, "", ""},
+		{"rm json failing", []string{"rm", "-json", filepath.Join(a, "x")}, exitFail, "", `[
+			{"path": ` + quote(filepath.Join(a, "x")) + `, "error": "*"}
+		]`},
+		{"rm no arguments", []string{"rm"}, exitUsage, "", ""},
+		{"rm", []string{"rm", "-json", sub}, exitOK, "", `[{"path": ` + quote(sub) + `}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, out, errOut := runGofs(tt.args...)
+			if code != tt.code {
+				t.Errorf("exit code = %d, want %d (stderr: %s)", code, tt.code, errOut)
+			}
+			if tt.code == exitUsage && !strings.Contains(errOut, "usage:") {
+				t.Errorf("stderr = %q, want the usage", errOut)
+			}
+			if tt.stdout != "" && out != tt.stdout {
+				t.Errorf("stdout = %q, want %q", out, tt.stdout)
+			}
+			if tt.json != "" {
+				var got, want any
+				if err := json.Unmarshal([]byte(out), &got); err != nil {
+					t.Fatalf("stdout is not JSON: %v\n%s", err, out)
+				}
+				if err := json.Unmarshal([]byte(tt.json), &want); err != nil {
+					t.Fatal(err)
+				}
+				maskErrors(got)
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("stdout = %s, want %s", out, tt.json)
+				}
+			}
+		})
+	}
+
+	if _, err := os.Stat(sub); !os.IsNotExist(err) {
+		t.Errorf("rm left %s in place", sub)
+	}
+}
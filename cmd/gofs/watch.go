@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"slices"
+	"syscall"
+	"time"
+
+	"github.com/renatopp/go-fs"
+)
+
+// watchEvent is the JSON representation of an event printed by watch.
+type watchEvent struct {
+	Op   string    `json:"op"`
+	Path string    `json:"path"`
+	Time time.Time `json:"time"`
+}
+
+func runWatch(args []string) error {
+	var asJson, gitIgnore bool
+	var pattern string
+	var debounce time.Duration
+	flags := newFlags("watch", &asJson)
+	flags.StringVar(&pattern, "g", "", "react only to paths matching the glob pattern")
+	flags.DurationVar(&debounce, "d", 100*time.Millisecond, "time to wait for further changes before running")
+	flags.BoolVar(&gitIgnore, "i", false, "ignore the paths listed in .gitignore files")
+
+	split := slices.Index(args, "--")
+	if split < 0 || split == len(args)-1 {
+		return errUsage
+	}
+	if err := parseFlags(flags, args[:split]); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		return errUsage
+	}
+	target := "."
+	if flags.NArg() == 1 {
+		target = flags.Arg(0)
+	}
+	if pattern != "" && !fs.IsPatternValid(pattern) {
+		return fmt.Errorf("invalid pattern %q", pattern)
+	}
+	command := args[split+1:]
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	trigger := make(chan struct{}, 1)
+	trigger <- struct{}{}
+	var timer *time.Timer
+	callback := func(event fs.Event) {
+		if event.Has(fs.EvtError) {
+			fmt.Fprintf(stderr, "gofs watch: %s\n", event.Err)
+			return
+		}
+		if asJson {
+			line, _ := json.Marshal(watchEvent{Op: event.String(), Path: event.Path, Time: event.Time})
+			fmt.Fprintln(stdout, string(line))
+		}
+		if timer == nil {
+			timer = time.AfterFunc(debounce, func() {
+				select {
+				case trigger <- struct{}{}:
+				default:
+				}
+			})
+		} else {
+			timer.Reset(debounce)
+		}
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		opts := []fs.WatchOption{fs.WithExclude(".git")}
+		if gitIgnore {
+			opts = append(opts, fs.WithGitIgnore())
+		}
+		switch {
+		case fs.IsFile(target):
+			done <- fs.WatchFile(ctx, target, callback)
+		case pattern != "":
+			done <- fs.WatchGlob(ctx, target, pattern, callback, opts...)
+		default:
+			done <- fs.WatchRecursive(ctx, target, callback, opts...)
+		}
+	}()
+
+	for {
+		select {
+		case <-trigger:
+			execute(ctx, command)
+		case err := <-done:
+			return err
+		}
+	}
+}
+
+// execute runs the command, forwarding its output. Failures are reported but
+// do not stop the watch.
+func execute(ctx context.Context, command []string) {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err := cmd.Run()
+	if err != nil && ctx.Err() == nil {
+		fmt.Fprintf(stderr, "gofs watch: %s\n", err)
+	}
+}
@@ -0,0 +1,102 @@
+package fs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AccessMode is a combination of the permissions checked by Access.
+type AccessMode uint32
+
+const (
+	AccessExists  AccessMode = 0 // Only check that the path exists
+	AccessExecute AccessMode = 1 // Execute files, search directories
+	AccessWrite   AccessMode = 2 // Write files, create entries in directories
+	AccessRead    AccessMode = 4 // Read files, list directories
+)
+
+// String returns the mode in the "rwx" form, with dashes for the permissions
+// not included (eg: "r-x").
+func (m AccessMode) String() string {
+	b := []byte("---")
+	if m&AccessRead != 0 {
+		b[0] = 'r'
+	}
+	if m&AccessWrite != 0 {
+		b[1] = 'w'
+	}
+	if m&AccessExecute != 0 {
+		b[2] = 'x'
+	}
+	return string(b)
+}
+
+// AccessError is returned by Access when the requested access is not granted.
+// Denied holds the permissions that were refused, and Err the underlying
+// reason, such as ErrPermission, ErrNotExist or a read-only file system.
+type AccessError struct {
+	Path   string
+	Mode   AccessMode
+	Denied AccessMode
+	Err    error
+}
+
+func (e *AccessError) Error() string {
+	if e.Denied == AccessExists {
+		return fmt.Sprintf("access %s: %s", e.Path, e.Err)
+	}
+	return fmt.Sprintf("access %s: %s denied: %s", e.Path, strings.Trim(e.Denied.String(), "-"), e.Err)
+}
+
+func (e *AccessError) Unwrap() error {
+	return e.Err
+}
+
+// Access checks whether the current process can access the file or directory
+// at the specified path with the given mode, a combination of AccessRead,
+// AccessWrite and AccessExecute. It returns nil if access is granted, or an
+// *AccessError describing which permissions were denied and why.
+//
+// The check is done by the system, as faccessat(2) does, using the effective
+// user and group IDs of the process, the ones used when actually opening
+// files. The file is never opened, so its access time is not updated and
+// FIFOs do not block. For directories, read means listing the entries, write
+// means creating and removing entries, and execute means searching it.
+//
+// On platforms without faccessat, such as Windows, the check is emulated
+// from the file mode: write is denied for read-only files and execute for
+// files without an extension listed in PATHEXT (.com, .exe, .bat and .cmd if
+// it is not set).
+func Access(p string, mode AccessMode) error {
+	return access(p, mode, true)
+}
+
+// AccessReal is like Access, but checks the permissions against the real user
+// and group IDs of the process instead of the effective ones, which matters
+// for set-user-ID programs.
+func AccessReal(p string, mode AccessMode) error {
+	return access(p, mode, false)
+}
+
+// CanAccess is like Access but reports whether access is granted as a boolean.
+func CanAccess(p string, mode AccessMode) bool {
+	return Access(p, mode) == nil
+}
+
+// access checks each requested permission separately, so the error reports
+// exactly which ones were denied.
+func access(p string, mode AccessMode, effective bool) error {
+	err := checkAccess(p, mode, effective)
+	if err == nil {
+		return nil
+	}
+	denied := AccessMode(0)
+	if mode != AccessExists && checkAccess(p, AccessExists, effective) == nil {
+		for _, m := range []AccessMode{AccessRead, AccessWrite, AccessExecute} {
+			if mode&m != 0 && checkAccess(p, m, effective) != nil {
+				denied |= m
+			}
+		}
+	}
+	return &AccessError{Path: p, Mode: mode, Denied: denied, Err: err}
+}
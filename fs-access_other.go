@@ -0,0 +1,35 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris)
+
+package fs
+
+import (
+	"os"
+	"runtime"
+)
+
+// checkAccess emulates faccessat(2) from the file mode, on platforms that do
+// not provide it. The owner permission bits are used, since other users and
+// groups are not meaningful there.
+func checkAccess(p string, mode AccessMode, effective bool) error {
+	info, err := os.Stat(p)
+	if err != nil {
+		return err
+	}
+	perm := info.Mode().Perm()
+	if mode&AccessRead != 0 && perm&0400 == 0 {
+		return ErrPermission
+	}
+	// Windows ignores the read-only attribute on directories.
+	if mode&AccessWrite != 0 && perm&0200 == 0 && !(info.IsDir() && runtime.GOOS == "windows") {
+		return ErrPermission
+	}
+	if mode&AccessExecute != 0 && !info.IsDir() {
+		if runtime.GOOS == "windows" && !hasExecutableExtension(p, getPathExt(os.Environ())) {
+			return ErrPermission
+		}
+		if runtime.GOOS != "windows" && perm&0100 == 0 {
+			return ErrPermission
+		}
+	}
+	return nil
+}
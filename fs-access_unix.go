@@ -0,0 +1,15 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly || solaris
+
+package fs
+
+import "golang.org/x/sys/unix"
+
+// checkAccess asks the system whether the path can be accessed with the given
+// mode, with faccessat(2).
+func checkAccess(p string, mode AccessMode, effective bool) error {
+	flags := 0
+	if effective {
+		flags = unix.AT_EACCESS
+	}
+	return unix.Faccessat(unix.AT_FDCWD, p, uint32(mode), flags)
+}
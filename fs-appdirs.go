@@ -0,0 +1,202 @@
+package fs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// AppDirectories holds the directories an application uses to store its
+// files, following the conventions of the current platform (the XDG base
+// directory specification on Unix systems).
+type AppDirectories struct {
+	Name       string   // Application name
+	Config     string   // User configuration files
+	Cache      string   // Non-essential cached data
+	Data       string   // User data files
+	State      string   // Data persisting between restarts, such as history
+	Runtime    string   // Sockets, named pipes and other runtime files
+	Log        string   // Log files
+	ConfigDirs []string // System-wide configuration directories, in order of priority
+	DataDirs   []string // System-wide data directories, in order of priority
+}
+
+// AppDirs resolves the directories of the application with the given name.
+// The directories are not created; use AppDirectories.Ensure for that.
+//
+// On Unix systems, the directories are the application subdirectories of the
+// XDG base directories, with logs stored in the state directory. On Darwin,
+// they live in ~/Library, and on Windows in %AppData% and %LocalAppData%.
+func AppDirs(appName string) (*AppDirectories, error) {
+	if appName == "" {
+		return nil, newError("AppDirs", appName, ErrInvalid)
+	}
+
+	config, err := GetConfigDir()
+	if err != nil {
+		return nil, newError("AppDirs", appName, err)
+	}
+	cache, err := GetCacheDir()
+	if err != nil {
+		return nil, newError("AppDirs", appName, err)
+	}
+	data, err := GetDataDir()
+	if err != nil {
+		return nil, newError("AppDirs", appName, err)
+	}
+	state, err := GetStateDir()
+	if err != nil {
+		return nil, newError("AppDirs", appName, err)
+	}
+	runtimeDir, err := GetRuntimeDir()
+	if err != nil {
+		runtimeDir = filepath.Join(os.TempDir(), fmt.Sprintf("%s-%d", appName, os.Getuid()))
+	} else {
+		runtimeDir = filepath.Join(runtimeDir, appName)
+	}
+
+	dirs := &AppDirectories{
+		Name:       appName,
+		Config:     filepath.Join(config, appName),
+		Cache:      filepath.Join(cache, appName),
+		Data:       filepath.Join(data, appName),
+		State:      filepath.Join(state, appName),
+		Runtime:    runtimeDir,
+		Log:        filepath.Join(state, appName, "log"),
+		ConfigDirs: []string{},
+		DataDirs:   []string{},
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		// Cache, data and state share %LocalAppData%, so they get their own
+		// subdirectories.
+		dirs.Cache = filepath.Join(cache, appName, "Cache")
+		dirs.Data = filepath.Join(data, appName, "Data")
+		dirs.State = filepath.Join(state, appName, "State")
+		dirs.Log = filepath.Join(state, appName, "Logs")
+	case "darwin", "ios":
+		if logs, err := getHomeSubDir("Library", "Logs", appName); err == nil {
+			dirs.Log = logs
+		}
+	}
+
+	for _, dir := range GetConfigDirs() {
+		dirs.ConfigDirs = append(dirs.ConfigDirs, filepath.Join(dir, appName))
+	}
+	for _, dir := range GetDataDirs() {
+		dirs.DataDirs = append(dirs.DataDirs, filepath.Join(dir, appName))
+	}
+	return dirs, nil
+}
+
+// ForceAppDirs is like AppDirs but it ignores any errors and returns nil in
+// such cases.
+func ForceAppDirs(appName string) *AppDirectories {
+	dirs, _ := AppDirs(appName)
+	return dirs
+}
+
+// Ensure creates the user directories of the application (config, cache,
+// data, state, runtime and log) if they do not exist, with permissions
+// restricted to the current user (0700) as the XDG specification requires.
+// System-wide directories are never created.
+//
+// As the runtime directory may live in a shared temporary directory, where
+// another user could have created it first, Ensure then checks that it is a
+// directory owned by the current user with mode 0700, and returns an error
+// wrapping ErrPermission otherwise. The check is skipped on Windows.
+func (d *AppDirectories) Ensure() error {
+	for _, dir := range []string{d.Config, d.Cache, d.Data, d.State, d.Runtime, d.Log} {
+		if IsFile(dir) {
+			return newError("Ensure", dir, ErrIsFile)
+		}
+		err := GetBackend().MkdirAll(dir, 0700)
+		if err != nil {
+			return newError("Ensure", dir, err)
+		}
+	}
+	return newError("Ensure", d.Runtime, checkPrivateDir(d.Runtime))
+}
+
+// checkPrivateDir checks that the path is a directory, not a symbolic link,
+// owned by the current user and only accessible by them.
+func checkPrivateDir(p string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	info, err := GetBackend().Lstat(p)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%w: not a directory", ErrPermission)
+	}
+	if uid, ok := getFileOwner(info); ok && uid != os.Getuid() {
+		return fmt.Errorf("%w: owned by user %d", ErrPermission, uid)
+	}
+	if info.Mode().Perm() != 0700 {
+		return fmt.Errorf("%w: mode %v, want %v", ErrPermission, info.Mode().Perm(), os.FileMode(0700))
+	}
+	return nil
+}
+
+// FindConfig returns the path of the first existing file or directory with the
+// given relative path, searching the user configuration directory and then the
+// system-wide ones in order of priority. If none exists, it returns an error
+// wrapping ErrNotExist.
+func (d *AppDirectories) FindConfig(rel string) (string, error) {
+	p, err := findIn(d.configSearchPath(), rel)
+	return p, newError("FindConfig", rel, err)
+}
+
+// FindAllConfig is like FindConfig, but returns every existing match in order
+// of priority, which is useful to merge layered configuration files.
+func (d *AppDirectories) FindAllConfig(rel string) []string {
+	return findAllIn(d.configSearchPath(), rel)
+}
+
+// FindData returns the path of the first existing file or directory with the
+// given relative path, searching the user data directory and then the
+// system-wide ones in order of priority. If none exists, it returns an error
+// wrapping ErrNotExist.
+func (d *AppDirectories) FindData(rel string) (string, error) {
+	p, err := findIn(d.dataSearchPath(), rel)
+	return p, newError("FindData", rel, err)
+}
+
+// FindAllData is like FindData, but returns every existing match in order of
+// priority.
+func (d *AppDirectories) FindAllData(rel string) []string {
+	return findAllIn(d.dataSearchPath(), rel)
+}
+
+func (d *AppDirectories) configSearchPath() []string {
+	return append([]string{d.Config}, d.ConfigDirs...)
+}
+
+func (d *AppDirectories) dataSearchPath() []string {
+	return append([]string{d.Data}, d.DataDirs...)
+}
+
+// findIn returns the first existing rel path inside the given directories.
+func findIn(dirs []string, rel string) (string, error) {
+	matches := findAllIn(dirs, rel)
+	if len(matches) == 0 {
+		return "", ErrNotExist
+	}
+	return matches[0], nil
+}
+
+// findAllIn returns every existing rel path inside the given directories.
+func findAllIn(dirs []string, rel string) []string {
+	matches := []string{}
+	for _, dir := range dirs {
+		p, err := SecureJoin(dir, rel)
+		if err == nil && Exists(p) {
+			matches = append(matches, p)
+		}
+	}
+	return matches
+}
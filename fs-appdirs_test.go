@@ -0,0 +1,44 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestEnsureRuntimeDirPermissions(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("XDG base directories are only used on linux")
+	}
+	base := t.TempDir()
+	for _, env := range []string{"XDG_CONFIG_HOME", "XDG_CACHE_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME", "XDG_RUNTIME_DIR"} {
+		t.Setenv(env, filepath.Join(base, env))
+	}
+
+	dirs, err := AppDirs("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dirs.Ensure(); err != nil {
+		t.Fatalf("Ensure() = %v", err)
+	}
+
+	if err := os.Chmod(dirs.Runtime, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := dirs.Ensure(); !errors.Is(err, ErrPermission) {
+		t.Fatalf("Ensure() with a 0755 runtime directory = %v, want ErrPermission", err)
+	}
+
+	if err := os.Remove(dirs.Runtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(base, dirs.Runtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := dirs.Ensure(); !errors.Is(err, ErrPermission) {
+		t.Fatalf("Ensure() with a symlinked runtime directory = %v, want ErrPermission", err)
+	}
+}
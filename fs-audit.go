@@ -0,0 +1,348 @@
+package fs
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// AuditRecord is a line of an AuditLog, describing a single mutation.
+type AuditRecord struct {
+	Time       time.Time `json:"time"`                  // When the operation started
+	Op         string    `json:"op"`                    // Function performing the mutation (eg: WriteFile)
+	Paths      []string  `json:"paths"`                 // Path of the operation, followed by the destination for copies and moves
+	Size       int64     `json:"size"`                  // Bytes written, or size of the file before the operation
+	HashBefore string    `json:"hash_before,omitempty"` // SHA256 of the file before the operation, with WithAuditHashes
+	HashAfter  string    `json:"hash_after,omitempty"`  // SHA256 of the file after the operation, with WithAuditHashes
+	Result     string    `json:"result"`                // "ok" or "error"
+	Error      string    `json:"error,omitempty"`       // Error message, if the operation failed
+	Prev       string    `json:"prev"`                  // Hash of the previous record
+	Hash       string    `json:"hash"`                  // Hash of this record, chained to Prev
+}
+
+// AuditOption configures an AuditLog.
+type AuditOption func(*AuditLog)
+
+// WithAuditHashes makes the log record the SHA256 of the files before and
+// after each operation. Hashing reads every file touched, so it slows down
+// operations on large files.
+func WithAuditHashes() AuditOption {
+	return func(a *AuditLog) {
+		a.hashes = true
+	}
+}
+
+// WithAuditRotation rotates the log once it grows over maxSize bytes, renaming
+// it with a ".1" suffix, the previous ".1" to ".2" and so on, keeping at most
+// keep rotated files. A keep of 0 keeps every rotated file.
+func WithAuditRotation(maxSize int64, keep int) AuditOption {
+	return func(a *AuditLog) {
+		a.maxSize = maxSize
+		a.keep = keep
+	}
+}
+
+// AuditLog is an append-only log of the mutations performed by WriteFile,
+// AppendFile, TruncateFile, Copy, Remove, EmptyDir, CreateDir, Move, Rename,
+// SetHidden, Link, Symlink, Chmod, SetMode, Chown and SetOwner, written as one
+// JSON record per line. Functions built on them, such as AppendFileString or
+// Empty, are recorded under the name of the function doing the mutation. Copy records each file it writes as a
+// "Copy", and EmptyDir each entry it removes as a "Remove". Each record holds
+// the hash of the previous one, so removing or editing a record breaks the
+// chain, as reported by VerifyAuditLog.
+//
+// Install it with SetAuditLog. The log is written directly to the operating
+// system, bypassing the Backend.
+type AuditLog struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	size    int64
+	last    string
+	hashes  bool
+	maxSize int64
+	keep    int
+}
+
+// OpenAuditLog opens the audit log at the specified path, creating it if it
+// does not exist. New records continue the hash chain of the existing ones.
+// The returned log must be closed when no longer needed.
+func OpenAuditLog(p string, opts ...AuditOption) (*AuditLog, error) {
+	a := &AuditLog{path: p}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	last, err := lastAuditHash(p)
+	if err == nil && last == "" {
+		last, err = lastAuditHash(a.rotatedPath(1))
+	}
+	if err != nil {
+		return nil, newError("OpenAuditLog", p, err)
+	}
+	a.last = last
+
+	if err := a.open(); err != nil {
+		return nil, newError("OpenAuditLog", p, err)
+	}
+	return a, nil
+}
+
+// Path returns the path of the log, as given to OpenAuditLog.
+func (a *AuditLog) Path() string {
+	return a.path
+}
+
+// Close closes the log.
+func (a *AuditLog) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return newError("Close", a.path, a.file.Close())
+}
+
+// Record appends the record to the log, filling its Prev and Hash fields, and
+// a zero Time with the current time.
+func (a *AuditLog) Record(rec AuditRecord) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+	rec.Prev = a.last
+	rec.Hash = ""
+	hash, err := hashAuditRecord(rec)
+	if err != nil {
+		return newError("Record", a.path, err)
+	}
+	rec.Hash = hash
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return newError("Record", a.path, err)
+	}
+	line = append(line, '\n')
+
+	if a.maxSize > 0 && a.size > 0 && a.size+int64(len(line)) > a.maxSize {
+		if err := a.rotate(); err != nil {
+			return newError("Record", a.path, err)
+		}
+	}
+	n, err := a.file.Write(line)
+	a.size += int64(n)
+	if err != nil {
+		return newError("Record", a.path, err)
+	}
+	a.last = hash
+	return nil
+}
+
+// open opens the current log file for appending.
+func (a *AuditLog) open() error {
+	f, err := os.OpenFile(a.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	a.file = f
+	a.size = info.Size()
+	return nil
+}
+
+// rotate closes the current log file, shifts the rotated ones and starts a
+// new one.
+func (a *AuditLog) rotate() error {
+	if err := a.file.Close(); err != nil {
+		return err
+	}
+	n := 1
+	for Exists(a.rotatedPath(n)) {
+		n++
+	}
+	for ; n > 1; n-- {
+		if a.keep > 0 && n > a.keep {
+			if err := os.Remove(a.rotatedPath(n - 1)); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.Rename(a.rotatedPath(n-1), a.rotatedPath(n)); err != nil {
+			return err
+		}
+	}
+	if err := os.Rename(a.path, a.rotatedPath(1)); err != nil {
+		return err
+	}
+	return a.open()
+}
+
+// rotatedPath returns the path of the nth rotated log file.
+func (a *AuditLog) rotatedPath(n int) string {
+	return a.path + "." + strconv.Itoa(n)
+}
+
+// hashAuditRecord returns the hash of the record, which includes the hash of
+// the previous one.
+func hashAuditRecord(rec AuditRecord) (string, error) {
+	rec.Hash = ""
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// lastAuditHash returns the hash of the last record of the log at the given
+// path, or an empty string if it does not exist or is empty.
+func lastAuditHash(p string) (string, error) {
+	last := ""
+	err := readAuditLog(p, func(rec AuditRecord, line int) error {
+		last = rec.Hash
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	return last, err
+}
+
+// readAuditLog calls fn with each record of the log at the given path and its
+// line number.
+func readAuditLog(p string, fn func(rec AuditRecord, line int) error) error {
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var rec AuditRecord
+			if jsonErr := json.Unmarshal(line, &rec); jsonErr != nil {
+				return fmt.Errorf("%w: line %d: %v", ErrTampered, n, jsonErr)
+			}
+			if fnErr := fn(rec, n); fnErr != nil {
+				return fnErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// VerifyAuditLog checks the hash chain of the audit logs at the given paths,
+// read in order as a single log, so rotated files must be passed from the
+// oldest to the newest (eg: "audit.log.2", "audit.log.1", "audit.log"). If a
+// record was edited, removed or reordered, it returns an error wrapping
+// ErrTampered that points to the first broken record.
+//
+// The Prev of the first record is not checked, as the records before it may
+// have been rotated away.
+func VerifyAuditLog(paths ...string) error {
+	prev, first := "", true
+	for _, p := range paths {
+		err := readAuditLog(p, func(rec AuditRecord, line int) error {
+			hash, err := hashAuditRecord(rec)
+			if err != nil {
+				return err
+			}
+			if hash != rec.Hash || (!first && rec.Prev != prev) {
+				return fmt.Errorf("%w: line %d", ErrTampered, line)
+			}
+			prev, first = rec.Hash, false
+			return nil
+		})
+		if err != nil {
+			return newError("VerifyAuditLog", p, err)
+		}
+	}
+	return nil
+}
+
+var (
+	auditMu  sync.RWMutex
+	auditLog *AuditLog
+)
+
+// SetAuditLog makes the functions listed in AuditLog record their mutations
+// into the given log, successful or not. A nil
+// log stops recording. The log is not closed when replaced.
+func SetAuditLog(a *AuditLog) {
+	auditMu.Lock()
+	defer auditMu.Unlock()
+	auditLog = a
+}
+
+// GetAuditLog returns the log set with SetAuditLog, or nil if there is none.
+func GetAuditLog() *AuditLog {
+	auditMu.RLock()
+	defer auditMu.RUnlock()
+	return auditLog
+}
+
+// audit runs fn, the mutation op over the given paths, recording it into the
+// audit log if there is one. A negative size is replaced by the size of the
+// first path, if it is a file. If recording fails, the error is returned
+// along with the error of fn.
+func audit(op string, paths []string, size int64, fn func() error) error {
+	a := GetAuditLog()
+	if a == nil {
+		return fn()
+	}
+
+	rec := AuditRecord{Time: time.Now(), Op: op, Paths: paths, Size: size}
+	if size < 0 {
+		rec.Size = 0
+		if info, err := GetBackend().Stat(paths[0]); err == nil && info.Mode().IsRegular() {
+			rec.Size = info.Size()
+		}
+	}
+	if a.hashes {
+		rec.HashBefore = auditHash(paths[0])
+	}
+	err := fn()
+	if a.hashes {
+		rec.HashAfter = auditHash(paths[len(paths)-1])
+	}
+	rec.Result = "ok"
+	if err != nil {
+		rec.Result = "error"
+		rec.Error = err.Error()
+	}
+	if recErr := a.Record(rec); recErr != nil {
+		return errors.Join(err, recErr)
+	}
+	return err
+}
+
+// auditHash returns the SHA256 of the file at the given path, or an empty
+// string if it is not a readable file.
+func auditHash(p string) string {
+	if info, err := GetBackend().Stat(p); err != nil || !info.Mode().IsRegular() {
+		return ""
+	}
+	sum, err := hashFile(context.Background(), p, sha256.New())
+	if err != nil {
+		return ""
+	}
+	return sum
+}
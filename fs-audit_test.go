@@ -0,0 +1,198 @@
+package fs
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestAuditRecords(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	for _, p := range []string{filepath.Join(src, "a"), filepath.Join(src, "sub", "b")} {
+		if err := EnsureDir(filepath.Dir(p)); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteFileString(p, "data"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logPath := filepath.Join(dir, "audit.log")
+	a, err := OpenAuditLog(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SetAuditLog(a)
+	defer SetAuditLog(nil)
+
+	if err := Copy(src, dst); err != nil {
+		t.Fatal(err)
+	}
+	if err := EmptyDir(dst); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dst, "file")
+	steps := []func() error{
+		func() error { return AppendFileString(file, "data") },
+		func() error { return AppendFileLines(file, []string{"a", "b"}) },
+		func() error { return AppendFileJson(file, 1) },
+		func() error { return TruncateFile(file, 2) },
+		func() error { return Empty(file) },
+		func() error { return CreateDir(filepath.Join(dst, "dir")) },
+		func() error { return Link(file, filepath.Join(dst, "link")) },
+		func() error { return Symlink(file, filepath.Join(dst, "symlink")) },
+		func() error { return Hide(filepath.Join(dst, "dir")) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err := ReadFileLines(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for _, line := range lines {
+		if line == "" {
+			continue
+		}
+		var rec AuditRecord
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, rec.Op+" "+ForceRelativePath(dir, rec.Paths[len(rec.Paths)-1]))
+	}
+	want := []string{
+		"Copy " + filepath.Join("dst", "a"),
+		"Copy " + filepath.Join("dst", "sub", "b"),
+		"Remove " + filepath.Join("dst", "a"),
+		"Remove " + filepath.Join("dst", "sub"),
+		"AppendFile " + filepath.Join("dst", "file"),
+		"AppendFile " + filepath.Join("dst", "file"),
+		"AppendFile " + filepath.Join("dst", "file"),
+		"TruncateFile " + filepath.Join("dst", "file"),
+		"TruncateFile " + filepath.Join("dst", "file"),
+		"CreateDir " + filepath.Join("dst", "dir"),
+		"Link " + filepath.Join("dst", "link"),
+		"Symlink " + filepath.Join("dst", "symlink"),
+		"SetHidden " + filepath.Join("dst", ".dir"),
+	}
+	if runtime.GOOS == "windows" {
+		want[len(want)-1] = "SetHidden " + filepath.Join("dst", "dir")
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("records = %q, want %q", got, want)
+	}
+	if err := VerifyAuditLog(logPath); err != nil {
+		t.Errorf("VerifyAuditLog() = %v", err)
+	}
+}
+
+// recordAudit appends n test records to the log.
+func recordAudit(t *testing.T, a *AuditLog, n int) {
+	t.Helper()
+	for i := range n {
+		if err := a.Record(AuditRecord{Op: "WriteFile", Paths: []string{strings.Repeat("x", i+1)}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestAuditRotation(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	a, err := OpenAuditLog(logPath, WithAuditRotation(600, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordAudit(t, a, 12)
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsFile(logPath+".1") || !IsFile(logPath+".2") || Exists(logPath+".3") {
+		t.Errorf("rotated logs = %v, want audit.log.1 and audit.log.2", ForceList(filepath.Dir(logPath)))
+	}
+	for _, p := range []string{logPath, logPath + ".1", logPath + ".2"} {
+		if size := ForceSize(p); size > 600 {
+			t.Errorf("Size(%s) = %d, want at most 600", filepath.Base(p), size)
+		}
+	}
+	if err := VerifyAuditLog(logPath+".2", logPath+".1", logPath); err != nil {
+		t.Errorf("VerifyAuditLog() = %v", err)
+	}
+}
+
+func TestAuditReopenContinuesChain(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	for range 2 {
+		a, err := OpenAuditLog(logPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		recordAudit(t, a, 2)
+		if err := a.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := ForceReadFileLines(logPath)
+	var second, third AuditRecord
+	if err := json.Unmarshal([]byte(lines[1]), &second); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(lines[2]), &third); err != nil {
+		t.Fatal(err)
+	}
+	if third.Prev != second.Hash {
+		t.Errorf("first record after reopening has Prev %q, want %q", third.Prev, second.Hash)
+	}
+	if err := VerifyAuditLog(logPath); err != nil {
+		t.Errorf("VerifyAuditLog() = %v", err)
+	}
+}
+
+func TestVerifyAuditLogTampered(t *testing.T) {
+	logPath := filepath.Join(t.TempDir(), "audit.log")
+	a, err := OpenAuditLog(logPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recordAudit(t, a, 3)
+	if err := a.Close(); err != nil {
+		t.Fatal(err)
+	}
+	lines := ForceReadFileLines(logPath)
+
+	tests := []struct {
+		name  string
+		lines []string
+		line  string
+	}{
+		{"edited", []string{lines[0], strings.Replace(lines[1], `"WriteFile"`, `"Remove"`, 1), lines[2]}, "line 2"},
+		{"removed", []string{lines[0], lines[2]}, "line 2"},
+		{"reordered", []string{lines[0], lines[2], lines[1]}, "line 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "audit.log")
+			if err := WriteFileLines(p, tt.lines); err != nil {
+				t.Fatal(err)
+			}
+			err := VerifyAuditLog(p)
+			if !errors.Is(err, ErrTampered) || !strings.Contains(err.Error(), tt.line) {
+				t.Errorf("VerifyAuditLog() = %v, want ErrTampered at %s", err, tt.line)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package fs
+
+import (
+	"io"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Names of the backend operations, as used by FaultBackend.
+const (
+	OpOpen     = "open"
+	OpRead     = "read"
+	OpWrite    = "write"
+	OpStat     = "stat"
+	OpReadDir  = "readdir"
+	OpMkdir    = "mkdir"
+	OpRemove   = "remove"
+	OpRename   = "rename"
+	OpChmod    = "chmod"
+	OpChown    = "chown"
+	OpLink     = "link"
+	OpSymlink  = "symlink"
+	OpReadlink = "readlink"
+	OpTruncate = "truncate"
+)
+
+// Backend is the file system the package functions operate on. By default, it
+// is the operating system; SetBackend replaces it, for example with a
+// FaultBackend to test how a program handles failures.
+//
+// The file and directory functions, such as ReadFile, WriteFile, Copy, Move,
+// Remove, Size, Hash and the List functions, go through the backend. Glob,
+// Watch, Root, Access and the lookup functions such as Which and FindUp always
+// use the operating system.
+type Backend interface {
+	Open(name string) (File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	ReadDir(name string) ([]os.DirEntry, error)
+	MkdirAll(name string, perm os.FileMode) error
+	Remove(name string) error
+	RemoveAll(name string) error
+	Rename(oldname, newname string) error
+	Chmod(name string, mode os.FileMode) error
+	Chown(name string, uid, gid int) error
+	Link(oldname, newname string) error
+	Symlink(oldname, newname string) error
+	Readlink(name string) (string, error)
+	Truncate(name string, size int64) error
+}
+
+// File is an open file of a Backend. *os.File implements it.
+type File interface {
+	io.Reader
+	io.Writer
+	io.Closer
+	Name() string
+	Stat() (os.FileInfo, error)
+}
+
+var (
+	backendMu sync.RWMutex
+	backend   Backend = osBackend{}
+)
+
+// SetBackend sets the backend used by the package functions. A nil backend
+// restores the operating system.
+func SetBackend(b Backend) {
+	if b == nil {
+		b = osBackend{}
+	}
+	backendMu.Lock()
+	defer backendMu.Unlock()
+	backend = b
+}
+
+// GetBackend returns the backend set with SetBackend.
+func GetBackend() Backend {
+	backendMu.RLock()
+	defer backendMu.RUnlock()
+	return backend
+}
+
+// OSBackend returns the backend of the operating system, which is the default
+// one. It is useful to build backends wrapping it.
+func OSBackend() Backend {
+	return osBackend{}
+}
+
+// osBackend implements Backend with the os package.
+type osBackend struct{}
+
+func (osBackend) Open(name string) (File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (osBackend) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (osBackend) Stat(name string) (os.FileInfo, error)        { return os.Stat(name) }
+func (osBackend) Lstat(name string) (os.FileInfo, error)       { return os.Lstat(name) }
+func (osBackend) ReadDir(name string) ([]os.DirEntry, error)   { return os.ReadDir(name) }
+func (osBackend) MkdirAll(name string, perm os.FileMode) error { return os.MkdirAll(name, perm) }
+func (osBackend) Remove(name string) error                     { return os.Remove(name) }
+func (osBackend) RemoveAll(name string) error                  { return os.RemoveAll(name) }
+func (osBackend) Rename(oldname, newname string) error         { return os.Rename(oldname, newname) }
+func (osBackend) Chmod(name string, mode os.FileMode) error    { return os.Chmod(name, mode) }
+func (osBackend) Chown(name string, uid, gid int) error        { return os.Chown(name, uid, gid) }
+func (osBackend) Link(oldname, newname string) error           { return os.Link(oldname, newname) }
+func (osBackend) Symlink(oldname, newname string) error        { return os.Symlink(oldname, newname) }
+func (osBackend) Readlink(name string) (string, error)         { return os.Readlink(name) }
+func (osBackend) Truncate(name string, size int64) error       { return os.Truncate(name, size) }
+
+// readFile is like os.ReadFile, reading through the backend.
+func readFile(p string) ([]byte, error) {
+	f, err := GetBackend().Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+// writeFile is like os.WriteFile, writing through the backend.
+func writeFile(p string, data []byte, perm os.FileMode) error {
+	f, err := GetBackend().OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// walkDir is like filepath.WalkDir, reading through the backend.
+func walkDir(root string, fn iofs.WalkDirFunc) error {
+	info, err := GetBackend().Lstat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDirEntry(root, iofs.FileInfoToDirEntry(info), fn)
+	}
+	if err == filepath.SkipDir || err == filepath.SkipAll {
+		return nil
+	}
+	return err
+}
+
+// walkDirEntry walks the entry at the given path, and its children if it is a
+// directory.
+func walkDirEntry(p string, d iofs.DirEntry, fn iofs.WalkDirFunc) error {
+	if err := fn(p, d, nil); err != nil || !d.IsDir() {
+		if err == filepath.SkipDir && d.IsDir() {
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := GetBackend().ReadDir(p)
+	if err != nil {
+		err = fn(p, d, err)
+		if err != nil {
+			if err == filepath.SkipDir && d.IsDir() {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, entry := range entries {
+		if err := walkDirEntry(filepath.Join(p, entry.Name()), entry, fn); err != nil {
+			if err == filepath.SkipDir {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
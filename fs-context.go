@@ -0,0 +1,73 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// contextError returns the error of the context if it is done, as a
+// *PathError with the path being processed, or nil otherwise.
+func contextError(ctx context.Context, p string) error {
+	if err := ctx.Err(); err != nil {
+		return &os.PathError{Op: "cancel", Path: p, Err: err}
+	}
+	return nil
+}
+
+// contextReader is a reader that stops once its context is done, so large
+// files can be interrupted in the middle.
+type contextReader struct {
+	ctx  context.Context
+	r    io.Reader
+	path string
+}
+
+// newContextReader returns a reader for r that stops once the context is done.
+// If the context can never be cancelled, r itself is returned, so copies keep
+// their fast paths.
+func newContextReader(ctx context.Context, r io.Reader, p string) io.Reader {
+	if ctx.Done() == nil {
+		return r
+	}
+	return &contextReader{ctx: ctx, r: r, path: p}
+}
+
+func (r *contextReader) Read(b []byte) (int, error) {
+	if err := contextError(r.ctx, r.path); err != nil {
+		return 0, err
+	}
+	return r.r.Read(b)
+}
+
+// removeAll is like os.RemoveAll, but checks the context before removing each
+// entry.
+func removeAll(ctx context.Context, p string) error {
+	if ctx.Done() == nil || filepath.Base(p) == "." {
+		return GetBackend().RemoveAll(p)
+	}
+	if err := contextError(ctx, p); err != nil {
+		return err
+	}
+	info, err := GetBackend().Lstat(p)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if info.IsDir() {
+		entries, err := GetBackend().ReadDir(p)
+		if err != nil && !errors.Is(err, ErrNotExist) {
+			return err
+		}
+		for _, entry := range entries {
+			if err := removeAll(ctx, filepath.Join(p, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return GetBackend().RemoveAll(p)
+}
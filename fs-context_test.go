@@ -0,0 +1,122 @@
+package fs
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// cancelAfter installs a middleware cancelling the context once an operation
+// with the given name completes on a path with the given base name.
+func cancelAfter(t *testing.T, op, base string) context.Context {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	Use(Middleware{After: func(_ context.Context, o Operation) {
+		if o.Name == op && filepath.Base(o.Paths[0]) == base {
+			cancel()
+		}
+	}})
+	t.Cleanup(func() {
+		Use()
+		cancel()
+	})
+	return ctx
+}
+
+// writeTree creates the files with the given contents under dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := EnsureDir(filepath.Dir(p)); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteFileString(p, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestCopyContextCancelledKeepsDestination(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"src":          "new",
+		"dst":          "old",
+		"srcdir/file":  "new",
+		"dstdir/file":  "old",
+		"srcdir/other": "new",
+	})
+	for _, tt := range []struct{ src, dst string }{
+		{"src", "dst"},
+		{"srcdir", "dstdir"},
+	} {
+		err := CopyContext(canceled, filepath.Join(dir, tt.src), filepath.Join(dir, tt.dst))
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("CopyContext(%s) error = %v, want context.Canceled", tt.src, err)
+		}
+	}
+	checkContent(t, filepath.Join(dir, "dst"), "old")
+	checkContent(t, filepath.Join(dir, "dstdir", "file"), "old")
+	if Exists(filepath.Join(dir, "dstdir", "other")) {
+		t.Errorf("CopyContext() copied a file after the cancellation")
+	}
+}
+
+func TestContextCancelledPartway(t *testing.T) {
+	files := map[string]string{
+		"a":     "alpha",
+		"b":     strings.Repeat("b", 256*1024),
+		"c/d":   "delta",
+		"c/e":   "echo",
+		"f/g/h": "hotel",
+	}
+	tests := []struct {
+		name     string
+		op, base string // Operation cancelling the context
+		run      func(ctx context.Context, dir string) error
+		check    func(t *testing.T, dir string)
+	}{
+		{"Hash", OpRead, "b", func(ctx context.Context, dir string) error {
+			_, err := HashContext(ctx, dir, sha256.New())
+			return err
+		}, nil},
+		{"Size", OpStat, "a", func(ctx context.Context, dir string) error {
+			_, err := SizeContext(ctx, dir)
+			return err
+		}, nil},
+		{"EmptyDir", OpRemove, "a", func(ctx context.Context, dir string) error {
+			return EmptyDirContext(ctx, dir)
+		}, func(t *testing.T, dir string) {
+			if Exists(filepath.Join(dir, "a")) || !Exists(filepath.Join(dir, "b")) {
+				t.Errorf("entries left = %v, want only a removed", ForceList(dir))
+			}
+		}},
+		{"Remove", OpRemove, "d", func(ctx context.Context, dir string) error {
+			return RemoveContext(ctx, dir)
+		}, func(t *testing.T, dir string) {
+			if Exists(filepath.Join(dir, "c", "d")) || !Exists(filepath.Join(dir, "c", "e")) {
+				t.Errorf("entries left = %v, want only c/d removed", ForceListRecursive(dir))
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeTree(t, dir, files)
+			ctx := cancelAfter(t, tt.op, tt.base)
+			if err := tt.run(ctx, dir); !errors.Is(err, context.Canceled) {
+				t.Fatalf("error = %v, want context.Canceled", err)
+			}
+			Use()
+			if tt.check != nil {
+				tt.check(t, dir)
+			}
+		})
+	}
+}
@@ -1,10 +1,12 @@
 package fs
 
 import (
+	"context"
 	"fmt"
 	"hash"
 	"os"
 	"path/filepath"
+	"runtime"
 	"slices"
 )
 
@@ -14,9 +16,9 @@ import (
 // directory.
 func isEmptyDir(p string) (bool, error) {
 	if !IsDir(p) {
-		return false, ErrNotDir
+		return false, notDirError(p)
 	}
-	entries, err := os.ReadDir(p)
+	entries, err := GetBackend().ReadDir(p)
 	if err != nil {
 		return false, err
 	}
@@ -25,25 +27,33 @@ func isEmptyDir(p string) (bool, error) {
 
 // copyDir recursively copies a directory from src to dst. If dst does not
 // exist, it will be created. If it exists, it will be merged with the src.
-func copyDir(src, dst string) error {
-	err := os.MkdirAll(dst, 0755)
-	if err != nil {
+func copyDir(src, dst string, b *bulk) error {
+	if err := b.check(src); err != nil {
 		return err
 	}
+	err := GetBackend().MkdirAll(dst, 0755)
+	if err != nil {
+		return b.fail(dst, err)
+	}
 
-	entries, err := os.ReadDir(src)
+	entries, err := GetBackend().ReadDir(src)
 	if err != nil {
-		return err
+		return b.fail(src, err)
 	}
 
 	for _, entry := range entries {
 		srcPath := filepath.Join(src, entry.Name())
 		dstPath := filepath.Join(dst, entry.Name())
+		if err := b.check(srcPath); err != nil {
+			return err
+		}
 
 		if entry.IsDir() {
-			err = copyDir(srcPath, dstPath)
+			err = copyDir(srcPath, dstPath, b)
+		} else if err = auditCopy(b.options, srcPath, dstPath); err != nil {
+			err = b.fail(srcPath, err)
 		} else {
-			err = copyFile(srcPath, dstPath)
+			b.done()
 		}
 		if err != nil {
 			return err
@@ -56,51 +66,76 @@ func copyDir(src, dst string) error {
 // directory using the provided hash function. It processes files recursively in a
 // deterministic order to ensure consistent results. It returns the final hash
 // as a hexadecimal string.
-func hashDir(p string, h hash.Hash) (string, error) {
+//
+// Every file and subdirectory is hashed on its own, resetting h before each
+// one, and the result is the hash of their hashes, so a file skipped after a
+// partial read leaves nothing in it.
+func hashDir(p string, h hash.Hash, b *bulk) (string, error) {
 	entries, err := List(p)
 	if err != nil {
-		return "", err
+		return "", b.fail(p, err)
 	}
 
 	slices.Sort(entries)
 
+	var sums []byte
 	for _, entry := range entries {
-		if IsDir(entry) {
-			subDirHash, err := hashDir(filepath.Join(p, entry), h)
+		if err := b.check(filepath.Join(p, entry)); err != nil {
+			return "", err
+		}
+		h.Reset()
+		if IsDir(filepath.Join(p, entry)) {
+			subDirHash, err := hashDir(filepath.Join(p, entry), h, b)
 			if err != nil {
 				return "", err
 			}
-			h.Write([]byte(subDirHash))
+			sums = append(sums, subDirHash...)
 		} else {
-			fileHash, err := hashFile(filepath.Join(p, entry), h)
+			fileHash, err := hashFile(b.ctx, filepath.Join(p, entry), h)
 			if err != nil {
-				return "", err
+				if err := b.fail(filepath.Join(p, entry), err); err != nil {
+					return "", err
+				}
+				continue
 			}
-			h.Write([]byte(fileHash))
+			b.done()
+			sums = append(sums, fileHash...)
 		}
 	}
 
+	h.Reset()
+	h.Write(sums)
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
 // sizeDir computes the total size of all files within the specified directory
 // and its subdirectories. It returns the total size in bytes.
-func sizeDir(p string) (int64, error) {
+func sizeDir(p string, b *bulk) (int64, error) {
 	if !IsDir(p) {
-		return 0, ErrNotDir
+		return 0, notDirError(p)
 	}
 
 	var totalSize int64 = 0
-	entries, err := ListFilesRecursive(p)
-	if err != nil {
-		return 0, err
-	}
-	for _, entry := range entries {
-		size, err := sizeFile(filepath.Join(p, entry))
+	err := walkDir(p, func(path string, d os.DirEntry, err error) error {
+		if err := b.check(path); err != nil {
+			return err
+		}
 		if err != nil {
-			return 0, err
+			return b.fail(path, err)
 		}
+		if d.IsDir() {
+			return nil
+		}
+		size, err := sizeFile(path)
+		if err != nil {
+			return b.fail(path, err)
+		}
+		b.done()
 		totalSize += size
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 	return totalSize, nil
 }
@@ -108,28 +143,46 @@ func sizeDir(p string) (int64, error) {
 // EmptyDir removes all contents of the directory at the specified path without
 // deleting the directory itself. If the directory does not exist, it returns
 // an error. If the path points to a file, it returns an error.
+//
+// Use EmptyDirContext to pass options.
 func EmptyDir(p string) error {
+	return EmptyDirContext(context.Background(), p)
+}
+
+// EmptyDirContext is like EmptyDir, but stops removing entries once the context
+// is done, returning an error wrapping the context error.
+//
+// With WithContinueOnError, it removes every entry it can and returns a
+// *MultiError listing the ones that could not be removed.
+func EmptyDirContext(ctx context.Context, p string, opts ...Option) error {
 	if !IsDir(p) {
-		return ErrNotDir
+		return newError("EmptyDir", p, notDirError(p))
 	}
-	entries, err := os.ReadDir(p)
+	entries, err := GetBackend().ReadDir(p)
 	if err != nil {
-		return err
+		return newError("EmptyDir", p, err)
 	}
+	b := newBulk("EmptyDir", newContextOptions(ctx, opts))
 	for _, entry := range entries {
 		entryPath := filepath.Join(p, entry.Name())
-		err := os.RemoveAll(entryPath)
+		err := audit("Remove", []string{entryPath}, -1, func() error {
+			return b.do(func() error { return removeAll(ctx, entryPath) })
+		})
 		if err != nil {
-			return err
+			if err := b.fail(entryPath, err); err != nil {
+				return newError("EmptyDir", entryPath, err)
+			}
+			continue
 		}
+		b.done()
 	}
-	return nil
+	return newError("EmptyDir", p, b.err())
 }
 
 // IsDir checks if the given p is a directory. If the p does not exist
 // or is a file, it returns false.
 func IsDir(p string) bool {
-	info, err := os.Stat(p)
+	info, err := GetBackend().Stat(p)
 	if err != nil {
 		return false
 	}
@@ -144,10 +197,10 @@ func IsDir(p string) bool {
 // This function is not recursive; it only lists entries in the specified
 // directory, not in its subdirectories.
 func ListDirs(p string) ([]string, error) {
-	entries, err := os.ReadDir(p)
+	entries, err := GetBackend().ReadDir(p)
 	dirs := []string{}
 	if err != nil {
-		return dirs, err
+		return dirs, newError("ListDirs", p, err)
 	}
 	for _, entry := range entries {
 		if entry.IsDir() {
@@ -173,11 +226,11 @@ func ForceListDirs(p string) []string {
 // and all its subdirectories.
 func ListDirsRecursive(p string) ([]string, error) {
 	if !IsDir(p) {
-		return nil, ErrNotDir
+		return nil, newError("ListDirsRecursive", p, notDirError(p))
 	}
 
 	results := []string{}
-	err := filepath.WalkDir(p, func(path string, d os.DirEntry, err error) error {
+	err := walkDir(p, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -193,7 +246,7 @@ func ListDirsRecursive(p string) ([]string, error) {
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		return nil, newError("ListDirsRecursive", p, err)
 	}
 	return results, nil
 }
@@ -209,7 +262,9 @@ func ForceListDirsRecursive(p string) []string {
 // parent directories. If the directory already exists, it does nothing and
 // returns nil.
 func CreateDir(p string) error {
-	return os.MkdirAll(p, 0755)
+	return newError("CreateDir", p, audit("CreateDir", []string{p}, 0, func() error {
+		return GetBackend().MkdirAll(p, 0755)
+	}))
 }
 
 // EnsureDir ensures that a directory exists at the specified path. It follows
@@ -221,19 +276,20 @@ func CreateDir(p string) error {
 //     necessary parent directories.
 func EnsureDir(p string) error {
 	if IsFile(p) {
-		return ErrIsFile
+		return newError("EnsureDir", p, ErrIsFile)
 	}
 	if Exists(p) {
 		return nil
 	}
-	return os.MkdirAll(p, 0755)
+	return newError("EnsureDir", p, GetBackend().MkdirAll(p, 0755))
 }
 
 // CreateTempDir creates a temporary directory with the specified prefix in the
 // system's default temporary directory. It returns the full path of the created
 // directory.
 func CreateTempDir(prefix string) (string, error) {
-	return os.MkdirTemp("", prefix)
+	dir, err := os.MkdirTemp("", prefix)
+	return dir, newError("CreateTempDir", prefix, err)
 }
 
 // ForceCreateTempDir is like CreateTempDir but it ignores any errors and
@@ -245,7 +301,8 @@ func ForceCreateTempDir(prefix string) string {
 
 // GetCurrentDir is an alias for Getwd.
 func GetCurrentDir() (string, error) {
-	return os.Getwd()
+	dir, err := os.Getwd()
+	return dir, newError("GetCurrentDir", "", err)
 }
 
 // ForceGetCurrentDir is like GetCurrentDir but it ignores any errors and
@@ -262,7 +319,8 @@ func GetTempDir() string {
 
 // GetCacheDir returns the cache directory of the current user.
 func GetCacheDir() (string, error) {
-	return os.UserCacheDir()
+	dir, err := os.UserCacheDir()
+	return dir, newError("GetCacheDir", "", err)
 }
 
 // ForceGetCacheDir is like GetCacheDir but it ignores any errors and
@@ -274,7 +332,8 @@ func ForceGetCacheDir() string {
 
 // GetConfigDir returns the configuration directory of the current user.
 func GetConfigDir() (string, error) {
-	return os.UserConfigDir()
+	dir, err := os.UserConfigDir()
+	return dir, newError("GetConfigDir", "", err)
 }
 
 // ForceGetConfigDir is like GetConfigDir but it ignores any errors and
@@ -286,7 +345,8 @@ func ForceGetConfigDir() string {
 
 // GetHomeDir returns the home directory of the current user.
 func GetHomeDir() (string, error) {
-	return os.UserHomeDir()
+	dir, err := os.UserHomeDir()
+	return dir, newError("GetHomeDir", "", err)
 }
 
 // ForceGetHomeDir is like GetHomeDir but it ignores any errors and
@@ -296,6 +356,174 @@ func ForceGetHomeDir() string {
 	return dir
 }
 
+// GetDataDir returns the data directory of the current user, where
+// applications store user-specific data files.
+//
+// On Unix systems, it returns $XDG_DATA_HOME if non-empty, else
+// $HOME/.local/share. On Darwin, it returns $HOME/Library/Application Support.
+// On Windows, it returns %LocalAppData%.
+func GetDataDir() (string, error) {
+	var dir string
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		dir, err = getEnvDir("LocalAppData")
+	case "darwin", "ios":
+		dir, err = getHomeSubDir("Library", "Application Support")
+	default:
+		dir, err = getXdgDir("XDG_DATA_HOME", ".local", "share")
+	}
+	return dir, newError("GetDataDir", "", err)
+}
+
+// ForceGetDataDir is like GetDataDir but it ignores any errors and returns
+// only the data directory path.
+func ForceGetDataDir() string {
+	dir, _ := GetDataDir()
+	return dir
+}
+
+// GetStateDir returns the state directory of the current user, where
+// applications store data that should persist between restarts but is not
+// important enough for the data directory, such as logs and history.
+//
+// On Unix systems, it returns $XDG_STATE_HOME if non-empty, else
+// $HOME/.local/state. On Darwin, it returns $HOME/Library/Application Support.
+// On Windows, it returns %LocalAppData%.
+func GetStateDir() (string, error) {
+	var dir string
+	var err error
+	switch runtime.GOOS {
+	case "windows":
+		dir, err = getEnvDir("LocalAppData")
+	case "darwin", "ios":
+		dir, err = getHomeSubDir("Library", "Application Support")
+	default:
+		dir, err = getXdgDir("XDG_STATE_HOME", ".local", "state")
+	}
+	return dir, newError("GetStateDir", "", err)
+}
+
+// ForceGetStateDir is like GetStateDir but it ignores any errors and returns
+// only the state directory path.
+func ForceGetStateDir() string {
+	dir, _ := GetStateDir()
+	return dir
+}
+
+// GetRuntimeDir returns the runtime directory of the current user, where
+// applications store sockets, named pipes and other runtime files.
+//
+// On Unix systems, it returns $XDG_RUNTIME_DIR, or an error if it is not set.
+// On Darwin and Windows, which have no such directory, it returns the
+// temporary directory.
+func GetRuntimeDir() (string, error) {
+	switch runtime.GOOS {
+	case "windows", "darwin", "ios":
+		return os.TempDir(), nil
+	}
+	dir := os.Getenv("XDG_RUNTIME_DIR")
+	if !filepath.IsAbs(dir) {
+		return "", newError("GetRuntimeDir", "", fmt.Errorf("%w: XDG_RUNTIME_DIR is not set", ErrNotExist))
+	}
+	return dir, nil
+}
+
+// ForceGetRuntimeDir is like GetRuntimeDir but it ignores any errors and
+// returns only the runtime directory path.
+func ForceGetRuntimeDir() string {
+	dir, _ := GetRuntimeDir()
+	return dir
+}
+
+// GetDataDirs returns the system-wide data directories, in order of priority.
+// They do not include the user data directory returned by GetDataDir.
+//
+// On Unix systems, it returns the paths in $XDG_DATA_DIRS, or
+// /usr/local/share and /usr/share if it is empty. On Darwin, it returns
+// /Library/Application Support. On Windows, it returns %ProgramData%.
+func GetDataDirs() []string {
+	switch runtime.GOOS {
+	case "windows":
+		return getEnvDirs("ProgramData")
+	case "darwin", "ios":
+		return []string{"/Library/Application Support"}
+	}
+	return getXdgDirs("XDG_DATA_DIRS", "/usr/local/share", "/usr/share")
+}
+
+// GetConfigDirs returns the system-wide configuration directories, in order of
+// priority. They do not include the user configuration directory returned by
+// GetConfigDir.
+//
+// On Unix systems, it returns the paths in $XDG_CONFIG_DIRS, or /etc/xdg if
+// it is empty. On Darwin, it returns /Library/Application Support. On Windows,
+// it returns %ProgramData%.
+func GetConfigDirs() []string {
+	switch runtime.GOOS {
+	case "windows":
+		return getEnvDirs("ProgramData")
+	case "darwin", "ios":
+		return []string{"/Library/Application Support"}
+	}
+	return getXdgDirs("XDG_CONFIG_DIRS", "/etc/xdg")
+}
+
+// getXdgDir returns the absolute path in the given environment variable, or
+// the given path inside the home directory if it is not set. Relative paths
+// are invalid according to the XDG specification and are ignored.
+func getXdgDir(env string, fallback ...string) (string, error) {
+	dir := os.Getenv(env)
+	if filepath.IsAbs(dir) {
+		return dir, nil
+	}
+	return getHomeSubDir(fallback...)
+}
+
+// getXdgDirs returns the absolute paths in the given list environment
+// variable, or the fallback paths if there are none.
+func getXdgDirs(env string, fallback ...string) []string {
+	dirs := []string{}
+	for _, dir := range filepath.SplitList(os.Getenv(env)) {
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+	if len(dirs) == 0 {
+		return fallback
+	}
+	return dirs
+}
+
+// getHomeSubDir returns the given path inside the home directory.
+func getHomeSubDir(elem ...string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(append([]string{home}, elem...)...), nil
+}
+
+// getEnvDir returns the directory in the given environment variable, or an
+// error if it is not set.
+func getEnvDir(env string) (string, error) {
+	dir := os.Getenv(env)
+	if dir == "" {
+		return "", fmt.Errorf("%w: %s is not set", ErrNotExist, env)
+	}
+	return dir, nil
+}
+
+// getEnvDirs returns the directory in the given environment variable as a
+// list, which is empty if it is not set.
+func getEnvDirs(env string) []string {
+	dir := os.Getenv(env)
+	if dir == "" {
+		return []string{}
+	}
+	return []string{dir}
+}
+
 // GetParentDir returns the parent directory of the given path p. If p is a file,
 // it returns the parent directory of that file. If p is a directory, it returns
 // that directory itself. If the path does not exist, it returns an error but
@@ -308,7 +536,7 @@ func GetParentDir(p string) (string, error) {
 	if IsDir(p) {
 		return p, nil
 	}
-	return GetPathParent(p), ErrNotExist
+	return GetPathParent(p), newError("GetParentDir", p, ErrNotExist)
 }
 
 // ForceGetParentDir is like GetParentDir but it ignores any errors and
@@ -331,7 +559,7 @@ func GetParentDirName(p string) (string, error) {
 	if IsDir(p) {
 		return GetPathBase(p), nil
 	}
-	return GetPathParentName(p), ErrNotExist
+	return GetPathParentName(p), newError("GetParentDirName", p, ErrNotExist)
 }
 
 // ForceGetParentDirName is like GetParentDirName but it ignores any errors and
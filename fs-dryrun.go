@@ -0,0 +1,262 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// PlanStep is an operation recorded by a DryRun.
+type PlanStep struct {
+	Op     string   // Function that would run (eg: Copy)
+	Paths  []string // Path of the operation, followed by the destination for copies and moves
+	Detail string   // What the operation would do, as far as it can be known (eg: 12 bytes)
+
+	apply func() error
+}
+
+// String describes the step in a single line (eg: Copy a.txt -> b.txt (12 bytes)).
+func (s PlanStep) String() string {
+	str := s.Op + " " + strings.Join(s.Paths, " -> ")
+	if s.Detail != "" {
+		str += " (" + s.Detail + ")"
+	}
+	return str
+}
+
+// Plan is the list of operations recorded by a DryRun, in order.
+type Plan struct {
+	Steps []PlanStep
+}
+
+// String describes the plan, one numbered step per line.
+func (p *Plan) String() string {
+	var b strings.Builder
+	for i, step := range p.Steps {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, step)
+	}
+	return b.String()
+}
+
+// Apply runs the steps of the plan for real, in order, with the options they
+// were recorded with. It stops at the first failure, returning an error
+// wrapping it along with the number of the step.
+func (p *Plan) Apply() error {
+	for i, step := range p.Steps {
+		if err := step.apply(); err != nil {
+			return fmt.Errorf("step %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// DryRun is a handle whose mutating functions record what they would do into
+// a Plan instead of doing it. Reads still hit the disk, so the functions fail
+// as the real ones would, for example when the source of a copy does not
+// exist. As the disk is never changed, each step sees the state before the
+// plan: moving a file and then copying its new path fails.
+//
+// Print the plan to review it, then Apply it to run it for real:
+//
+//	dry := fs.NewDryRun()
+//	dry.Remove("build")
+//	dry.Copy("dist", "build")
+//	fmt.Print(dry.Plan())
+//	err := dry.Plan().Apply()
+type DryRun struct {
+	mu    sync.Mutex
+	steps []PlanStep
+}
+
+// NewDryRun returns a DryRun with an empty plan.
+func NewDryRun() *DryRun {
+	return &DryRun{}
+}
+
+// Plan returns the operations recorded so far.
+func (d *DryRun) Plan() *Plan {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &Plan{Steps: slices.Clone(d.steps)}
+}
+
+// Reset discards the recorded operations.
+func (d *DryRun) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.steps = nil
+}
+
+// record appends a step to the plan.
+func (d *DryRun) record(op string, paths []string, detail string, apply func() error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.steps = append(d.steps, PlanStep{Op: op, Paths: paths, Detail: detail, apply: apply})
+}
+
+// Copy records a Copy from src to dst. It fails if src does not exist.
+func (d *DryRun) Copy(src, dst string, opts ...Option) error {
+	info, err := GetBackend().Stat(src)
+	if err != nil {
+		return newLinkError("Copy", src, dst, err)
+	}
+	detail := describeSize(src, info)
+	if Exists(dst) && info.IsDir() {
+		detail += ", merges"
+	} else if Exists(dst) {
+		detail += ", overwrites"
+	}
+	d.record("Copy", []string{src, dst}, detail, func() error {
+		return CopyContext(context.Background(), src, dst, opts...)
+	})
+	return nil
+}
+
+// Move records a Move from src to dst. It fails if src does not exist.
+func (d *DryRun) Move(src, dst string, opts ...Option) error {
+	info, err := GetBackend().Lstat(src)
+	if err != nil {
+		return newLinkError("Move", src, dst, err)
+	}
+	detail := describeSize(src, info)
+	if Exists(dst) {
+		detail += ", replaces"
+	}
+	d.record("Move", []string{src, dst}, detail, func() error {
+		return MoveContext(context.Background(), src, dst, opts...)
+	})
+	return nil
+}
+
+// Remove records a Remove of the path. As Remove, it does not fail if the
+// path does not exist.
+func (d *DryRun) Remove(p string, opts ...Option) error {
+	detail := "does not exist"
+	if info, err := GetBackend().Lstat(p); err == nil {
+		detail = describeSize(p, info)
+	}
+	d.record("Remove", []string{p}, detail, func() error {
+		return RemoveContext(context.Background(), p, opts...)
+	})
+	return nil
+}
+
+// EmptyDir records an EmptyDir of the directory. It fails if the path is not
+// a directory.
+func (d *DryRun) EmptyDir(p string, opts ...Option) error {
+	if !IsDir(p) {
+		return newError("EmptyDir", p, notDirError(p))
+	}
+	entries, err := GetBackend().ReadDir(p)
+	if err != nil {
+		return newError("EmptyDir", p, err)
+	}
+	d.record("EmptyDir", []string{p}, plural(len(entries), "entry", "entries"), func() error {
+		return EmptyDirContext(context.Background(), p, opts...)
+	})
+	return nil
+}
+
+// WriteFile records a WriteFile of the data. The data is copied, so the
+// caller may reuse it.
+func (d *DryRun) WriteFile(p string, data []byte, opts ...Option) error {
+	detail := plural(len(data), "byte", "bytes")
+	if info, err := GetBackend().Stat(p); err == nil {
+		if info.IsDir() {
+			return newError("WriteFile", p, ErrIsDir)
+		}
+		detail += fmt.Sprintf(", overwrites %s", plural(int(info.Size()), "byte", "bytes"))
+	}
+	data = bytes.Clone(data)
+	d.record("WriteFile", []string{p}, detail, func() error {
+		return WriteFileContext(context.Background(), p, data, opts...)
+	})
+	return nil
+}
+
+// ReplaceInFile records a ReplaceInFile. It fails if the file cannot be read,
+// and records nothing if old is not found, as ReplaceInFile would not write
+// the file. When applied, the replacement runs over the content at that time.
+func (d *DryRun) ReplaceInFile(p string, old []byte, new []byte) error {
+	data, err := ReadFile(p)
+	if err != nil {
+		return newError("ReplaceInFile", p, err)
+	}
+	n := bytes.Count(data, old)
+	if n == 0 {
+		return nil
+	}
+	old, new = bytes.Clone(old), bytes.Clone(new)
+	d.record("ReplaceInFile", []string{p}, plural(n, "replacement", "replacements"), func() error {
+		return ReplaceInFile(p, old, new)
+	})
+	return nil
+}
+
+// SetHidden records a SetHidden of the path. It fails if the path does not
+// exist, and records nothing if the path is already in the requested state.
+func (d *DryRun) SetHidden(p string, hidden bool) error {
+	if _, err := GetBackend().Lstat(p); err != nil {
+		return newError("SetHidden", p, err)
+	}
+	isHidden, err := IsHidden(p)
+	if err != nil {
+		return newError("SetHidden", p, err)
+	}
+	if isHidden == hidden {
+		return nil
+	}
+	detail := "hide"
+	if !hidden {
+		detail = "unhide"
+	}
+	if runtime.GOOS != "windows" {
+		base := "." + filepath.Base(p)
+		if !hidden {
+			base = strings.TrimPrefix(filepath.Base(p), ".")
+		}
+		detail += ", renames to " + filepath.Join(filepath.Dir(p), base)
+	}
+	d.record("SetHidden", []string{p}, detail, func() error {
+		return SetHidden(p, hidden)
+	})
+	return nil
+}
+
+// Chmod records a Chmod of the path. It fails if the path does not exist.
+func (d *DryRun) Chmod(p string, mode os.FileMode) error {
+	info, err := GetBackend().Stat(p)
+	if err != nil {
+		return newError("Chmod", p, err)
+	}
+	detail := fmt.Sprintf("%v -> %v", info.Mode().Perm(), mode)
+	d.record("Chmod", []string{p}, detail, func() error {
+		return Chmod(p, mode)
+	})
+	return nil
+}
+
+// describeSize describes the size of the file, or the entries and size of
+// the directory, at the given path.
+func describeSize(p string, info os.FileInfo) string {
+	if !info.IsDir() {
+		return plural(int(info.Size()), "byte", "bytes")
+	}
+	entries, _ := ListRecursive(p)
+	size, _ := Size(p)
+	return fmt.Sprintf("directory, %s, %s", plural(len(entries), "entry", "entries"), plural(int(size), "byte", "bytes"))
+}
+
+// plural formats the count with the singular or plural noun.
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return "1 " + singular
+	}
+	return fmt.Sprintf("%d %s", n, plural)
+}
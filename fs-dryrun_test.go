@@ -0,0 +1,137 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDryRunLeavesDiskUntouched(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"dist/index.html": "v={{version}}", "dist/app.js": "js", "build/old": "o", "file": "data"})
+	join := func(name string) string { return filepath.Join(dir, filepath.FromSlash(name)) }
+	before, err := TakeSnapshot(dir, WithSnapshotHash(sha256.New))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dry := NewDryRun()
+	steps := []func() error{
+		func() error { return dry.Remove(join("build")) },
+		func() error { return dry.Copy(join("dist"), join("build")) },
+		func() error { return dry.Move(join("file"), join("moved")) },
+		func() error { return dry.EmptyDir(join("dist")) },
+		func() error { return dry.WriteFile(join("new"), []byte("new")) },
+		func() error {
+			return dry.ReplaceInFile(join("dist/index.html"), []byte("{{version}}"), []byte("1.2.0"))
+		},
+		func() error { return dry.SetHidden(join("file"), true) },
+		func() error { return dry.Chmod(join("file"), 0600) },
+	}
+	for i, step := range steps {
+		if err := step(); err != nil {
+			t.Fatalf("step %d: %v", i+1, err)
+		}
+	}
+	// Each step sees the disk before the plan, where moved does not exist.
+	if err := dry.SetHidden(join("moved"), true); !errors.Is(err, ErrNotExist) {
+		t.Errorf("SetHidden() of a path moved by the plan error = %v, want ErrNotExist", err)
+	}
+	if err := dry.Copy(join("missing"), join("x")); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Copy() of a missing source error = %v, want ErrNotExist", err)
+	}
+	if err := dry.ReplaceInFile(join("file"), []byte("absent"), nil); err != nil {
+		t.Errorf("ReplaceInFile() without matches error = %v", err)
+	}
+
+	changes, _, err := before.Changes(WithSnapshotHash(sha256.New))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("recording changed the disk: %q", changeStrings(changes))
+	}
+	// Failed calls and replacements without matches record nothing.
+	if got := len(dry.Plan().Steps); got != len(steps) {
+		t.Errorf("plan has %d steps, want %d:\n%s", got, len(steps), dry.Plan())
+	}
+}
+
+func TestPlanString(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"dist/index.html": "v={{version}}", "dist/app.js": "js", "build/old": "o", "file": "data"})
+	join := func(name string) string { return filepath.Join(dir, filepath.FromSlash(name)) }
+	info, err := os.Stat(join("file"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dry := NewDryRun()
+	dry.Remove(join("build"))
+	dry.Copy(join("dist"), join("build"))
+	dry.Remove(join("missing"))
+	dry.WriteFile(join("file"), []byte("new"))
+	dry.ReplaceInFile(join("dist/index.html"), []byte("{{version}}"), []byte("1.2.0"))
+	dry.Chmod(join("file"), 0600)
+
+	want := strings.Join([]string{
+		"1. Remove " + join("build") + " (directory, 1 entry, 1 byte)",
+		"2. Copy " + join("dist") + " -> " + join("build") + " (directory, 2 entries, 15 bytes, merges)",
+		"3. Remove " + join("missing") + " (does not exist)",
+		"4. WriteFile " + join("file") + " (3 bytes, overwrites 4 bytes)",
+		"5. ReplaceInFile " + join("dist/index.html") + " (1 replacement)",
+		fmt.Sprintf("6. Chmod %s (%v -> %v)", join("file"), info.Mode().Perm(), os.FileMode(0600)),
+	}, "\n") + "\n"
+	if got := dry.Plan().String(); got != want {
+		t.Errorf("Plan().String() =\n%s\nwant\n%s", got, want)
+	}
+
+	dry.Reset()
+	if got := dry.Plan().String(); got != "" {
+		t.Errorf("Plan().String() after Reset() = %q, want empty", got)
+	}
+}
+
+func TestPlanApply(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"dist/index.html": "v={{version}}", "build/old": "o"})
+	join := func(name string) string { return filepath.Join(dir, filepath.FromSlash(name)) }
+
+	dry := NewDryRun()
+	dry.Remove(join("build"))
+	dry.Copy(join("dist"), join("build"))
+	dry.WriteFile(join("out"), []byte("first"))
+	dry.WriteFile(join("out"), []byte("second"))
+	dry.ReplaceInFile(join("dist/index.html"), []byte("{{version}}"), []byte("1.2.0"))
+	if err := dry.Plan().Apply(); err != nil {
+		t.Fatal(err)
+	}
+	if Exists(join("build/old")) {
+		t.Errorf("Apply() did not remove build before copying dist into it")
+	}
+	checkContent(t, join("build/index.html"), "v={{version}}")
+	checkContent(t, join("dist/index.html"), "v=1.2.0")
+	checkContent(t, join("out"), "second")
+
+	// The second step fails, so the third one does not run.
+	writeTree(t, dir, map[string]string{"src": "src"})
+	dry.Reset()
+	dry.WriteFile(join("a"), []byte("a"))
+	dry.Move(join("src"), join("dst"))
+	dry.WriteFile(join("b"), []byte("b"))
+	if err := Remove(join("src")); err != nil {
+		t.Fatal(err)
+	}
+	err := dry.Plan().Apply()
+	if !errors.Is(err, ErrNotExist) || !strings.HasPrefix(err.Error(), "step 2: ") {
+		t.Errorf("Apply() error = %v, want step 2 failing with ErrNotExist", err)
+	}
+	checkContent(t, join("a"), "a")
+	if Exists(join("b")) {
+		t.Errorf("Apply() ran the step after the failing one")
+	}
+}
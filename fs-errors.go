@@ -0,0 +1,184 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+)
+
+// Error records a failed operation of this package, with the path that caused
+// it. Errors from the os package are unwrapped, so Path holds the exact entry
+// that failed, which may be a file inside the directory given to a recursive
+// operation such as Copy or Remove.
+//
+// The classification helpers, such as IsNotExist and IsTransient, as well as
+// errors.Is and errors.As, see through Error to the underlying cause.
+type Error struct {
+	Op   string // Function that failed (eg: ReadFile)
+	Path string // Path that caused the failure
+	Dest string // Destination path of operations such as Copy and Move, if any
+	Err  error  // Underlying cause
+}
+
+func (e *Error) Error() string {
+	if e.Dest != "" {
+		return e.Op + " " + e.Path + " -> " + e.Dest + ": " + e.Err.Error()
+	}
+	if e.Path == "" {
+		return e.Op + ": " + e.Err.Error()
+	}
+	return e.Op + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// newError wraps err as an *Error of the given operation on the given path. It
+// returns nil if err is nil.
+//
+// A *PathError is replaced by its path and cause, and a *LinkError by its
+// paths and cause. An *Error returned by another function of this package is
+// renamed to the given operation, keeping the path that failed.
+func newError(op, p string, err error) error {
+	return newLinkError(op, p, "", err)
+}
+
+// newLinkError is like newError, for operations involving two paths.
+func newLinkError(op, src, dst string, err error) error {
+	if err == nil {
+		return nil
+	}
+	e := &Error{Op: op, Path: src, Dest: dst, Err: err}
+	switch err := err.(type) {
+	case *Error:
+		e.Path, e.Dest, e.Err = err.Path, err.Dest, err.Err
+	case *os.PathError:
+		e.Path, e.Dest, e.Err = err.Path, "", err.Err
+	case *os.LinkError:
+		e.Path, e.Dest, e.Err = err.Old, err.New, err.Err
+	}
+	return e
+}
+
+// IsNotExist reports whether err indicates that a file or directory does not
+// exist.
+func IsNotExist(err error) bool {
+	return errors.Is(err, ErrNotExist)
+}
+
+// IsExist reports whether err indicates that a file or directory already
+// exists.
+func IsExist(err error) bool {
+	return errors.Is(err, ErrExist)
+}
+
+// IsPermission reports whether err indicates that the operation was denied,
+// due to permissions or a read-only file system.
+func IsPermission(err error) bool {
+	return errors.Is(err, ErrPermission)
+}
+
+// IsCrossDevice reports whether err indicates that the operation, usually a
+// Move or Link, cannot be done across file systems or volumes.
+func IsCrossDevice(err error) bool {
+	return isErrorIn(err, crossDeviceErrors)
+}
+
+// IsNoSpace reports whether err indicates that the device is full or the disk
+// quota was exceeded.
+func IsNoSpace(err error) bool {
+	return isErrorIn(err, noSpaceErrors)
+}
+
+// IsTransient reports whether err is likely to go away if the operation is
+// retried, such as a busy file, a sharing violation on Windows or an
+// interrupted system call.
+func IsTransient(err error) bool {
+	return isErrorIn(err, transientErrors)
+}
+
+// isErrorIn reports whether err matches any of the given errors.
+func isErrorIn(err error, targets []error) bool {
+	return err != nil && slices.ContainsFunc(targets, func(target error) bool {
+		return errors.Is(err, target)
+	})
+}
+
+// notDirError returns the reason why the path is not a directory, which is the
+// stat error if it cannot be accessed, or ErrNotDir otherwise.
+func notDirError(p string) error {
+	if _, err := GetBackend().Stat(p); err != nil {
+		return err
+	}
+	return ErrNotDir
+}
+
+// MultiError is returned by recursive operations running with
+// WithContinueOnError, listing every entry that failed. Each error is an
+// *Error with the path of the entry and its cause.
+//
+// errors.Is and the classification helpers match if any of the errors does,
+// so IsPermission reports whether some entry failed due to permissions.
+type MultiError struct {
+	Errors    []error // Failures, in the order they happened
+	Succeeded int     // Number of entries processed successfully
+}
+
+func (e *MultiError) Error() string {
+	msg := fmt.Sprintf("%d failed, %d succeeded", len(e.Errors), e.Succeeded)
+	for _, err := range e.Errors {
+		msg += "\n\t" + err.Error()
+	}
+	return msg
+}
+
+func (e *MultiError) Unwrap() []error {
+	return e.Errors
+}
+
+// bulk tracks the entries processed by a recursive operation. By default, the
+// first failure aborts the operation; with WithContinueOnError, failures are
+// collected and the operation goes on.
+type bulk struct {
+	options
+	op        string
+	errors    []error
+	succeeded int
+}
+
+func newBulk(op string, o options) *bulk {
+	return &bulk{options: o, op: op}
+}
+
+// check returns an error if the operation was cancelled before processing the
+// entry at the given path.
+func (b *bulk) check(p string) error {
+	return contextError(b.ctx, p)
+}
+
+// fail records the failure of the entry at the given path. It returns the
+// error if the operation must be aborted, or nil to continue. Cancellation
+// always aborts.
+func (b *bulk) fail(p string, err error) error {
+	if !b.continueOnError || b.ctx.Err() != nil {
+		return err
+	}
+	b.errors = append(b.errors, newError(b.op, p, err))
+	return nil
+}
+
+// done records the success of an entry.
+func (b *bulk) done() {
+	b.succeeded++
+}
+
+// err returns the collected failures as a *MultiError, or nil if there were
+// none.
+func (b *bulk) err() error {
+	if len(b.errors) == 0 {
+		return nil
+	}
+	return &MultiError{Errors: b.errors, Succeeded: b.succeeded}
+}
@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package fs
+
+import "syscall"
+
+var (
+	crossDeviceErrors = []error{syscall.EXDEV}
+	noSpaceErrors     = []error{syscall.ENOSPC, syscall.EDQUOT}
+	transientErrors   = []error{syscall.EAGAIN, syscall.EBUSY, syscall.EINTR, syscall.ETIMEDOUT}
+)
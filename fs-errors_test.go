@@ -0,0 +1,392 @@
+package fs
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// errorCase is a call of a public function that must fail with an *Error of
+// the given operation and path, matching want with errors.Is.
+type errorCase struct {
+	name string
+	fn   func() error
+	op   string
+	path string // Expected Path, not checked if empty
+	want error  // Expected cause, not checked if nil
+}
+
+func checkErrorCases(t *testing.T, cases []errorCase) {
+	t.Helper()
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.fn()
+			var e *Error
+			if !errors.As(err, &e) {
+				t.Fatalf("error = %v (%T), want an *Error", err, err)
+			}
+			if e.Op != tc.op {
+				t.Errorf("Op = %q, want %q (error: %v)", e.Op, tc.op, err)
+			}
+			if tc.path != "" && e.Path != tc.path {
+				t.Errorf("Path = %q, want %q (error: %v)", e.Path, tc.path, err)
+			}
+			if tc.want != nil && !errors.Is(err, tc.want) {
+				t.Errorf("error = %v, want it to match %v", err, tc.want)
+			}
+		})
+	}
+}
+
+// withFault runs fn with a backend failing every operation op with err.
+func withFault(op string, err error, fn func() error) func() error {
+	return func() error {
+		faults := NewFaultBackend(OSBackend())
+		faults.FailOn(op, "**", err)
+		SetBackend(faults)
+		defer SetBackend(nil)
+		return fn()
+	}
+}
+
+// TestErrorMatrix checks the error returned by every public function that can
+// fail, so callers can rely on *Error and the classifiers.
+func TestErrorMatrix(t *testing.T) {
+	dir := t.TempDir()
+	join := func(elem ...string) string {
+		return filepath.Join(append([]string{dir}, elem...)...)
+	}
+	file := join("file")
+	sub := join("sub")
+	missing := join("missing")
+	badJson := join("bad.json")
+	if err := WriteFileString(file, "data"); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileString(badJson, "{"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateDir(sub); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileString(filepath.Join(sub, "entry"), "entry"); err != nil {
+		t.Fatal(err)
+	}
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	unmarshalable := func() {}
+
+	dry := NewDryRun()
+	root, err := OpenRoot(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+	audit, err := OpenAuditLog(join("audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit.Close()
+	tx, err := BeginTx(join("tx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	watcher, err := NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Close()
+
+	checkErrorCases(t, []errorCase{
+		// fs-appdirs.go
+		{"AppDirs", func() error { _, err := AppDirs(""); return err }, "AppDirs", "", ErrInvalid},
+		{"Ensure", func() error { return (&AppDirectories{Config: file}).Ensure() }, "Ensure", file, ErrIsFile},
+		{"FindConfig", func() error { _, err := (&AppDirectories{}).FindConfig("x"); return err }, "FindConfig", "x", ErrNotExist},
+		{"FindData", func() error { _, err := (&AppDirectories{}).FindData("x"); return err }, "FindData", "x", ErrNotExist},
+
+		// fs-audit.go
+		{"OpenAuditLog", func() error { _, err := OpenAuditLog(join("missing", "audit.log")); return err }, "OpenAuditLog", "", ErrNotExist},
+		{"AuditLog.Close", audit.Close, "Close", "", ErrClosed},
+		{"AuditLog.Record", func() error { return audit.Record(AuditRecord{}) }, "Record", "", ErrClosed},
+		{"VerifyAuditLog", func() error { return VerifyAuditLog(missing) }, "VerifyAuditLog", missing, ErrNotExist},
+		{"VerifyAuditLog tampered", func() error { return VerifyAuditLog(badJson) }, "VerifyAuditLog", badJson, ErrTampered},
+
+		// fs-dirs.go
+		{"EmptyDir missing", func() error { return EmptyDir(missing) }, "EmptyDir", missing, ErrNotExist},
+		{"EmptyDir file", func() error { return EmptyDir(file) }, "EmptyDir", file, ErrNotDir},
+		{"EmptyDirContext", func() error { return EmptyDirContext(canceled, sub) }, "EmptyDir", "", context.Canceled},
+		{"ListDirs", func() error { _, err := ListDirs(missing); return err }, "ListDirs", missing, ErrNotExist},
+		{"ListDirsRecursive", func() error { _, err := ListDirsRecursive(missing); return err }, "ListDirsRecursive", missing, ErrNotExist},
+		{"CreateDir", func() error { return CreateDir(filepath.Join(file, "x")) }, "CreateDir", "", nil},
+		{"EnsureDir", func() error { return EnsureDir(file) }, "EnsureDir", file, ErrIsFile},
+		{"CreateTempDir", func() error { _, err := CreateTempDir("a/b"); return err }, "CreateTempDir", "", nil},
+		{"GetParentDir", func() error { _, err := GetParentDir(missing); return err }, "GetParentDir", missing, ErrNotExist},
+		{"GetParentDirName", func() error { _, err := GetParentDirName(missing); return err }, "GetParentDirName", missing, ErrNotExist},
+
+		// fs-dryrun.go
+		{"DryRun.Copy", func() error { return dry.Copy(missing, join("x")) }, "Copy", missing, ErrNotExist},
+		{"DryRun.Move", func() error { return dry.Move(missing, join("x")) }, "Move", missing, ErrNotExist},
+		{"DryRun.EmptyDir", func() error { return dry.EmptyDir(file) }, "EmptyDir", file, ErrNotDir},
+		{"DryRun.WriteFile", func() error { return dry.WriteFile(sub, nil) }, "WriteFile", sub, ErrIsDir},
+		{"DryRun.ReplaceInFile", func() error { return dry.ReplaceInFile(missing, nil, nil) }, "ReplaceInFile", missing, ErrNotExist},
+		{"DryRun.SetHidden", func() error { return dry.SetHidden(missing, true) }, "SetHidden", missing, ErrNotExist},
+		{"DryRun.Chmod", func() error { return dry.Chmod(missing, 0644) }, "Chmod", missing, ErrNotExist},
+		{"Plan.Apply", func() error {
+			src := join("plan")
+			if err := WriteFileString(src, "data"); err != nil {
+				return err
+			}
+			d := NewDryRun()
+			if err := d.Copy(src, join("copy")); err != nil {
+				return err
+			}
+			os.Remove(src)
+			return d.Plan().Apply()
+		}, "Copy", join("plan"), ErrNotExist},
+
+		// fs-files.go
+		{"ListFiles", func() error { _, err := ListFiles(missing); return err }, "ListFiles", missing, ErrNotExist},
+		{"ListFilesRecursive", func() error { _, err := ListFilesRecursive(missing); return err }, "ListFilesRecursive", missing, ErrNotExist},
+		{"ReadFile", func() error { _, err := ReadFile(missing); return err }, "ReadFile", missing, ErrNotExist},
+		{"ReadFileString", func() error { _, err := ReadFileString(missing); return err }, "ReadFileString", missing, ErrNotExist},
+		{"ReadFileLines", func() error { _, err := ReadFileLines(missing); return err }, "ReadFileLines", missing, ErrNotExist},
+		{"ReadFileJson", func() error { var v any; return ReadFileJson(missing, &v) }, "ReadFileJson", missing, ErrNotExist},
+		{"ReadFileJson invalid", func() error { var v any; return ReadFileJson(badJson, &v) }, "ReadFileJson", badJson, nil},
+		{"ReadFileJsonAs", func() error { _, err := ReadFileJsonAs[any](missing); return err }, "ReadFileJsonAs", missing, ErrNotExist},
+		{"WriteFile", func() error { return WriteFile(filepath.Join(missing, "x"), nil) }, "WriteFile", "", ErrNotExist},
+		{"WriteFileContext", func() error { return WriteFileContext(canceled, filepath.Join(missing, "x"), nil) }, "WriteFile", "", context.Canceled},
+		{"WriteFileString", func() error { return WriteFileString(filepath.Join(missing, "x"), "") }, "WriteFileString", "", ErrNotExist},
+		{"WriteFileLines", func() error { return WriteFileLines(filepath.Join(missing, "x"), nil) }, "WriteFileLines", "", ErrNotExist},
+		{"WriteFileJson", func() error { return WriteFileJson(filepath.Join(missing, "x"), 1) }, "WriteFileJson", "", ErrNotExist},
+		{"WriteFileJson invalid", func() error { return WriteFileJson(join("x"), unmarshalable) }, "WriteFileJson", join("x"), nil},
+		{"AppendFile", func() error { return AppendFile(filepath.Join(missing, "x"), nil) }, "AppendFile", "", ErrNotExist},
+		{"AppendFileString", func() error { return AppendFileString(filepath.Join(missing, "x"), "") }, "AppendFileString", "", ErrNotExist},
+		{"AppendFileLines", func() error { return AppendFileLines(filepath.Join(missing, "x"), nil) }, "AppendFileLines", "", ErrNotExist},
+		{"AppendFileJson", func() error { return AppendFileJson(filepath.Join(missing, "x"), 1) }, "AppendFileJson", "", ErrNotExist},
+		{"TouchFile", func() error { return TouchFile(filepath.Join(missing, "x")) }, "TouchFile", "", ErrNotExist},
+		{"EnsureFile", func() error { return EnsureFile(sub) }, "EnsureFile", sub, ErrIsDir},
+		{"ReplaceInFile", func() error { return ReplaceInFile(missing, nil, nil) }, "ReplaceInFile", missing, ErrNotExist},
+		{"ReplaceInFileString", func() error { return ReplaceInFileString(missing, "", "") }, "ReplaceInFileString", missing, ErrNotExist},
+		{"CreateTempFile", func() error { _, err := CreateTempFile("a/b"); return err }, "CreateTempFile", "", nil},
+		{"CreateTempFileOpen", func() error { _, err := CreateTempFileOpen("a/b"); return err }, "CreateTempFileOpen", "", nil},
+		{"TruncateFile", func() error { return TruncateFile(missing, 0) }, "TruncateFile", missing, ErrNotExist},
+
+		// fs-find.go
+		{"FindUp", func() error { _, err := FindUp(dir, "fs-no-such-name"); return err }, "FindUp", dir, ErrNotExist},
+		{"FindUp invalid", func() error { _, err := FindUp(dir, "["); return err }, "FindUp", dir, ErrInvalid},
+		{"FindUpUntil", func() error { _, err := FindUpUntil(dir, StopAt(dir), "fs-no-such-name"); return err }, "FindUpUntil", dir, ErrNotExist},
+		{"FindAllUp", func() error { _, err := FindAllUp(dir, "["); return err }, "FindAllUp", dir, ErrInvalid},
+		{"FindAllUpUntil", func() error { _, err := FindAllUpUntil(missing, nil, "x"); return err }, "FindAllUpUntil", missing, ErrNotExist},
+
+		// fs-general.go
+		{"IsEmpty", func() error { _, err := IsEmpty(missing); return err }, "IsEmpty", missing, ErrNotExist},
+		{"Walk", func() error { return Walk(missing, func(string) error { return nil }) }, "Walk", missing, ErrNotExist},
+		{"List", func() error { _, err := List(missing); return err }, "List", missing, ErrNotExist},
+		{"ListRecursive", func() error { _, err := ListRecursive(missing); return err }, "ListRecursive", missing, ErrNotExist},
+		{"ListRecursiveContext", func() error { _, err := ListRecursiveContext(canceled, dir); return err }, "ListRecursive", "", context.Canceled},
+		{"Glob", func() error { _, err := Glob(dir, "["); return err }, "Glob", "", nil},
+		{"GlobContext", func() error { _, err := GlobContext(canceled, dir, "*"); return err }, "Glob", "", context.Canceled},
+		{"Match", func() error { _, err := Match("a", "["); return err }, "Match", "", nil},
+		{"Copy", func() error { return Copy(missing, join("x")) }, "Copy", missing, ErrNotExist},
+		{"CopyContext", func() error { return CopyContext(canceled, file, join("x")) }, "Copy", "", context.Canceled},
+		{"Move", func() error { return Move(missing, join("x")) }, "Move", missing, ErrNotExist},
+		{"MoveContext", func() error { return MoveContext(canceled, missing, join("x")) }, "Move", missing, context.Canceled},
+		{"Rename", func() error { return Rename(missing, join("x")) }, "Rename", missing, ErrNotExist},
+		{"RenameContext", func() error { return RenameContext(canceled, missing, join("x")) }, "Rename", missing, context.Canceled},
+		{"Remove", withFault(OpRemove, ErrPermission, func() error { return Remove(file) }), "Remove", file, ErrPermission},
+		{"RemoveContext", func() error { return RemoveContext(canceled, sub) }, "Remove", "", context.Canceled},
+		{"SetMode", func() error { return SetMode(missing, 0644) }, "SetMode", missing, ErrNotExist},
+		{"SetHidden", func() error { return SetHidden(missing, true) }, "SetHidden", missing, ErrNotExist},
+		{"Hide", func() error { return Hide(missing) }, "Hide", missing, ErrNotExist},
+		{"Unhide", func() error { return Unhide(join(".missing")) }, "Unhide", join(".missing"), ErrNotExist},
+		{"Chmod", func() error { return Chmod(missing, 0644) }, "Chmod", missing, ErrNotExist},
+		{"Chown", func() error { return Chown(missing, 0, 0) }, "Chown", missing, nil},
+		{"Chdir", func() error { return Chdir(missing) }, "Chdir", missing, ErrNotExist},
+		{"SetOwner", func() error { return SetOwner(missing, 0, 0) }, "SetOwner", missing, nil},
+		{"Empty", func() error { return Empty(missing) }, "Empty", missing, ErrNotExist},
+		{"Link", func() error { return Link(missing, join("x")) }, "Link", missing, ErrNotExist},
+		{"Symlink", func() error { return Symlink("x", file) }, "Symlink", "", ErrExist},
+		{"Readlink", func() error { _, err := Readlink(missing); return err }, "Readlink", missing, ErrNotExist},
+		{"MD5", func() error { _, err := MD5(missing); return err }, "MD5", missing, ErrNotExist},
+		{"SHA1", func() error { _, err := SHA1(missing); return err }, "SHA1", missing, ErrNotExist},
+		{"SHA256", func() error { _, err := SHA256(missing); return err }, "SHA256", missing, ErrNotExist},
+		{"Checksum", func() error { _, err := Checksum(missing); return err }, "Checksum", missing, ErrNotExist},
+		{"Hash", func() error { _, err := Hash(missing, sha256.New()); return err }, "Hash", missing, ErrNotExist},
+		{"HashContext", func() error { _, err := HashContext(canceled, sub, sha256.New()); return err }, "Hash", "", context.Canceled},
+		{"Size", func() error { _, err := Size(missing); return err }, "Size", missing, ErrNotExist},
+		{"SizeContext", func() error { _, err := SizeContext(canceled, sub); return err }, "Size", "", context.Canceled},
+		{"GetModTime", func() error { _, err := GetModTime(missing); return err }, "GetModTime", missing, ErrNotExist},
+		{"GetInfo", func() error { _, err := GetInfo(missing); return err }, "GetInfo", missing, ErrNotExist},
+		{"GetMode", func() error { _, err := GetMode(missing); return err }, "GetMode", missing, ErrNotExist},
+
+		// fs-path-type.go
+		{"Path.ReadFile", func() error { _, err := Path(missing).ReadFile(); return err }, "ReadFile", missing, ErrNotExist},
+		{"Path.ReadString", func() error { _, err := Path(missing).ReadString(); return err }, "ReadFileString", missing, ErrNotExist},
+		{"Path.ReadJson", func() error { var v any; return Path(missing).ReadJson(&v) }, "ReadFileJson", missing, ErrNotExist},
+		{"Path.WriteFile", func() error { return Path(missing).Join("x").WriteFile(nil) }, "WriteFile", "", ErrNotExist},
+		{"Path.WriteString", func() error { return Path(missing).Join("x").WriteString("") }, "WriteFileString", "", ErrNotExist},
+		{"Path.WriteJson", func() error { return Path(missing).Join("x").WriteJson(1) }, "WriteFileJson", "", ErrNotExist},
+		{"Path.List", func() error { _, err := Path(missing).List(); return err }, "List", missing, ErrNotExist},
+		{"Path.Walk", func() error { return Path(missing).Walk(func(Path) error { return nil }) }, "Walk", missing, ErrNotExist},
+
+		// fs-path.go
+		{"AbsolutePath", func() error { _, err := AbsolutePath("$FS_TEST_UNSET", WithStrictExpand()); return err }, "AbsolutePath", "$FS_TEST_UNSET", ErrUndefinedVariable},
+		{"ExpandPath", func() error { _, err := ExpandPath("${FS_TEST_UNSET"); return err }, "ExpandPath", "${FS_TEST_UNSET", ErrInvalid},
+
+		// fs-reload.go
+		{"NewReloadable", func() error { _, err := NewReloadable[any](context.Background(), missing); return err }, "NewReloadable", missing, ErrNotExist},
+		{"NewReloadableFunc", func() error {
+			_, err := NewReloadableFunc[any](context.Background(), badJson, func([]byte, any) error { return ErrInvalid })
+			return err
+		}, "NewReloadable", badJson, ErrInvalid},
+		{"Reloadable.Reload", func() error {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			p := join("reload.json")
+			if err := WriteFileString(p, "{}"); err != nil {
+				return err
+			}
+			r, err := NewReloadable[any](ctx, p)
+			if err != nil {
+				return err
+			}
+			os.Remove(p)
+			r.Reload()
+			return r.Err()
+		}, "Reload", join("reload.json"), ErrNotExist},
+
+		// fs-root.go
+		{"OpenRoot", func() error { _, err := OpenRoot(missing); return err }, "OpenRoot", missing, ErrNotExist},
+		{"Root.OpenRoot", func() error { _, err := root.OpenRoot("missing"); return err }, "OpenRoot", "missing", ErrNotExist},
+		{"Root.Open", func() error { _, err := root.Open("missing"); return err }, "Open", "missing", ErrNotExist},
+		{"Root.OpenFile", func() error { _, err := root.OpenFile("missing", os.O_RDONLY, 0); return err }, "OpenFile", "missing", ErrNotExist},
+		{"Root.GetInfo", func() error { _, err := root.GetInfo("missing"); return err }, "GetInfo", "missing", ErrNotExist},
+		{"Root.ReadFile", func() error { _, err := root.ReadFile("missing"); return err }, "ReadFile", "missing", ErrNotExist},
+		{"Root.ReadFileString", func() error { _, err := root.ReadFileString("missing"); return err }, "ReadFileString", "missing", ErrNotExist},
+		{"Root.ReadFileLines", func() error { _, err := root.ReadFileLines("missing"); return err }, "ReadFileLines", "missing", ErrNotExist},
+		{"Root.ReadFileJson", func() error { var v any; return root.ReadFileJson("missing", &v) }, "ReadFileJson", "missing", ErrNotExist},
+		{"Root.WriteFile", func() error { return root.WriteFile("missing/x", nil) }, "WriteFile", "", ErrNotExist},
+		{"Root.WriteFileString", func() error { return root.WriteFileString("missing/x", "") }, "WriteFileString", "", ErrNotExist},
+		{"Root.WriteFileLines", func() error { return root.WriteFileLines("missing/x", nil) }, "WriteFileLines", "", ErrNotExist},
+		{"Root.WriteFileJson", func() error { return root.WriteFileJson("missing/x", 1) }, "WriteFileJson", "", ErrNotExist},
+		{"Root.AppendFile", func() error { return root.AppendFile("missing/x", nil) }, "AppendFile", "", ErrNotExist},
+		{"Root.List", func() error { _, err := root.List("missing"); return err }, "List", "", ErrNotExist},
+		{"Root.ListRecursive", func() error { _, err := root.ListRecursive("missing"); return err }, "ListRecursive", "", ErrNotExist},
+		{"Root.CreateDir", func() error { return root.CreateDir("file/x") }, "CreateDir", "", nil},
+		{"Root.Copy", func() error { return root.Copy("missing", "x") }, "Copy", "", ErrNotExist},
+		{"Root.Move", func() error { return root.Move("missing", "x") }, "Move", "", ErrNotExist},
+		{"Root.Remove", func() error { return root.Remove("../x") }, "Remove", "", nil},
+		{"Root.EmptyDir", func() error { return root.EmptyDir("missing") }, "EmptyDir", "missing", ErrNotExist},
+		{"Root.Chmod", func() error { return root.Chmod("missing", 0644) }, "Chmod", "", ErrNotExist},
+		{"Root.Symlink", func() error { return root.Symlink("x", "file") }, "Symlink", "", ErrExist},
+
+		// fs-secure.go
+		{"SecureJoin", func() error { _, err := SecureJoin(dir, "a\x00"); return err }, "SecureJoin", "", ErrInvalid},
+
+		// fs-snapshot.go
+		{"TakeSnapshot", func() error { _, err := TakeSnapshot(missing); return err }, "TakeSnapshot", missing, ErrNotExist},
+		{"LoadSnapshot", func() error { _, err := LoadSnapshot(missing); return err }, "LoadSnapshot", missing, ErrNotExist},
+		{"Snapshot.Save", func() error { return (&Snapshot{}).Save(filepath.Join(missing, "x")) }, "Save", "", ErrNotExist},
+		{"Snapshot.Changes", func() error { _, _, err := (&Snapshot{Root: missing}).Changes(); return err }, "TakeSnapshot", missing, ErrNotExist},
+
+		// fs-tx.go
+		{"BeginTx", func() error { _, err := BeginTx(filepath.Join(file, "x")); return err }, "BeginTx", "", nil},
+		{"Tx.WriteFile", func() error {
+			return withTx(t, join("tx"), func(tx *Tx) error { return tx.WriteFile(filepath.Join(missing, "x"), nil) })
+		}, "WriteFile", "", ErrNotExist},
+		{"Tx.Copy", func() error { return withTx(t, join("tx"), func(tx *Tx) error { return tx.Copy(missing, join("x")) }) }, "Copy", missing, ErrNotExist},
+		{"Tx.Move", func() error { return withTx(t, join("tx"), func(tx *Tx) error { return tx.Move(missing, join("x")) }) }, "Move", missing, ErrNotExist},
+		{"Tx.Remove", func() error {
+			return withTx(t, join("tx"), func(tx *Tx) error {
+				return withFault(OpRemove, ErrPermission, func() error { return tx.Remove(missing) })()
+			})
+		}, "Remove", missing, ErrPermission},
+		{"Tx.Commit", tx.Commit, "Commit", "", ErrTxDone},
+		{"Tx.Rollback", tx.Rollback, "Rollback", "", ErrTxDone},
+		{"RecoverTx", func() error { return RecoverTx(file) }, "RecoverTx", file, nil},
+
+		// fs-wait.go
+		{"WaitForExists", func() error { return WaitForExists(canceled, missing) }, "WaitForExists", missing, context.Canceled},
+		{"WaitForRemoved", func() error { return WaitForRemoved(canceled, file) }, "WaitForRemoved", file, context.Canceled},
+		{"WaitForStable", func() error { return WaitForStable(canceled, missing, 0) }, "WaitForStable", missing, context.Canceled},
+		{"WaitForContent", func() error { return WaitForContent(canceled, missing, nil) }, "WaitForContent", missing, context.Canceled},
+
+		// fs-watch.go
+		{"Watcher.Add", func() error { return watcher.Add(missing) }, "Add", missing, ErrNotExist},
+		{"Watcher.Remove", func() error { return watcher.Remove(missing) }, "Remove", missing, nil},
+		{"Watch", func() error { return Watch(canceled, missing, func(Event) {}) }, "Watch", missing, ErrNotExist},
+		{"WatchFile", func() error { return WatchFile(canceled, filepath.Join(missing, "x"), func(Event) {}) }, "WatchFile", "", ErrNotExist},
+		{"WatchRecursive", func() error { return WatchRecursive(canceled, missing, func(Event) {}) }, "WatchRecursive", missing, ErrNotExist},
+		{"WatchGlob", func() error { return WatchGlob(canceled, dir, "[", func(Event) {}) }, "WatchGlob", "[", ErrInvalid},
+
+		// fs-which.go
+		{"Which", func() error { _, err := Which("fs-no-such-command"); return err }, "Which", "fs-no-such-command", ErrNotExist},
+		{"WhichAll", func() error { _, err := WhichAll("fs-no-such-command"); return err }, "WhichAll", "fs-no-such-command", ErrNotExist},
+		{"WhichEnv", func() error { _, err := WhichEnv("sh", []string{"PATH=" + dir}); return err }, "WhichEnv", "sh", ErrNotExist},
+		{"WhichAllEnv", func() error { _, err := WhichAllEnv("sh", []string{"PATH=" + dir}); return err }, "WhichAllEnv", "sh", ErrNotExist},
+	})
+
+	// Access and SecureJoin return their own structured errors.
+	var accessErr *AccessError
+	if err := Access(missing, AccessRead); !errors.As(err, &accessErr) || !IsNotExist(err) {
+		t.Errorf("Access() = %v, want an *AccessError wrapping ErrNotExist", err)
+	}
+	if err := AccessReal(missing, AccessRead); !errors.As(err, &accessErr) || !IsNotExist(err) {
+		t.Errorf("AccessReal() = %v, want an *AccessError wrapping ErrNotExist", err)
+	}
+	var escapeErr *EscapeError
+	if _, err := SecureJoin(dir, "../x"); !errors.As(err, &escapeErr) || !errors.Is(err, ErrEscapesRoot) {
+		t.Errorf("SecureJoin() = %v, want an *EscapeError", err)
+	}
+}
+
+// TestErrorMatrixEnvironment checks the functions failing when the
+// environment or the working directory are broken.
+func TestErrorMatrixEnvironment(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("the environment variables are only used on linux")
+	}
+	for _, env := range []string{"HOME", "XDG_CACHE_HOME", "XDG_CONFIG_HOME", "XDG_DATA_HOME", "XDG_STATE_HOME", "XDG_RUNTIME_DIR"} {
+		t.Setenv(env, "")
+	}
+	dir := t.TempDir()
+	t.Chdir(dir)
+	if err := os.Remove(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	checkErrorCases(t, []errorCase{
+		{"GetCacheDir", func() error { _, err := GetCacheDir(); return err }, "GetCacheDir", "", nil},
+		{"GetConfigDir", func() error { _, err := GetConfigDir(); return err }, "GetConfigDir", "", nil},
+		{"GetHomeDir", func() error { _, err := GetHomeDir(); return err }, "GetHomeDir", "", nil},
+		{"GetDataDir", func() error { _, err := GetDataDir(); return err }, "GetDataDir", "", nil},
+		{"GetStateDir", func() error { _, err := GetStateDir(); return err }, "GetStateDir", "", nil},
+		{"GetRuntimeDir", func() error { _, err := GetRuntimeDir(); return err }, "GetRuntimeDir", "", ErrNotExist},
+		{"GetCurrentDir", func() error { _, err := GetCurrentDir(); return err }, "GetCurrentDir", "", ErrNotExist},
+		{"Getwd", func() error { _, err := Getwd(); return err }, "GetCurrentDir", "", ErrNotExist},
+		{"Pwd", func() error { _, err := Pwd(); return err }, "GetCurrentDir", "", ErrNotExist},
+		{"Path.Abs", func() error { _, err := Path("x").Abs(); return err }, "AbsolutePath", "", ErrNotExist},
+		{"Path.Rel", func() error { _, err := Path("x").Rel("y"); return err }, "RelativePath", "", ErrNotExist},
+		{"AbsolutePath", func() error { _, err := AbsolutePath("x"); return err }, "AbsolutePath", "", ErrNotExist},
+		{"RelativePath", func() error { _, err := RelativePath("x", "y"); return err }, "RelativePath", "", ErrNotExist},
+	})
+}
+
+// withTx runs fn in a transaction, rolling it back afterwards.
+func withTx(t *testing.T, journalDir string, fn func(tx *Tx) error) error {
+	t.Helper()
+	tx, err := BeginTx(journalDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	return fn(tx)
+}
@@ -0,0 +1,9 @@
+package fs
+
+import "golang.org/x/sys/windows"
+
+var (
+	crossDeviceErrors = []error{windows.ERROR_NOT_SAME_DEVICE}
+	noSpaceErrors     = []error{windows.ERROR_DISK_FULL, windows.ERROR_HANDLE_DISK_FULL, windows.ERROR_DISK_QUOTA_EXCEEDED}
+	transientErrors   = []error{windows.ERROR_SHARING_VIOLATION, windows.ERROR_LOCK_VIOLATION, windows.ERROR_BUSY, windows.ERROR_SEM_TIMEOUT}
+)
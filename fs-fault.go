@@ -0,0 +1,374 @@
+package fs
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Fault describes a failure injected by a FaultBackend into the operations
+// matching its Op and Path. When a fault triggers, it waits for Delay, then
+// crashes if Crash is set, and finally fails the operation with Err, if any.
+//
+// For reads and writes, AfterBytes lets that many bytes go through, counted
+// over every file the fault matches, before failing; if Err is nil, writes
+// then fail as on a full disk. ShortWrite limits each write to that many
+// bytes, which then fails with io.ErrShortWrite.
+type Fault struct {
+	Op         string        // Operation to fail (eg: OpOpen), empty for all
+	Path       string        // Glob pattern matched against the path, empty for all
+	Err        error         // Error returned by the operation
+	Delay      time.Duration // Wait before the operation, to simulate slow devices
+	AfterBytes int64         // Bytes read or written successfully before failing
+	ShortWrite int           // Maximum number of bytes written per write, 0 for no limit
+	Crash      bool          // Panic with an error wrapping ErrCrashed, to simulate the process dying
+	Times      int           // Number of operations the fault triggers on, 0 for unlimited
+
+	triggered int
+	bytes     int64
+}
+
+// FaultBackend is a Backend that injects failures into the operations of
+// another backend, to test how a program handles them. Install it with
+// SetBackend, then script the failures with Inject or the helpers:
+//
+//	faults := fs.NewFaultBackend(fs.OSBackend())
+//	faults.NoSpaceAfter("**/*.json", 1024)
+//	fs.SetBackend(faults)
+//	defer fs.SetBackend(nil)
+//
+//	err := fs.WriteFileJson("out/data.json", data) // fs.IsNoSpace(err) is true
+//
+// When several faults match an operation, the first one injected wins.
+type FaultBackend struct {
+	backend Backend
+	mu      sync.Mutex
+	faults  []*Fault
+}
+
+// NewFaultBackend returns a FaultBackend wrapping the given backend.
+func NewFaultBackend(b Backend) *FaultBackend {
+	return &FaultBackend{backend: b}
+}
+
+// Inject adds a fault. It returns the fault, whose fields must not be changed
+// afterwards.
+func (f *FaultBackend) Inject(fault Fault) *Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	injected := &fault
+	f.faults = append(f.faults, injected)
+	return injected
+}
+
+// RemoveFault removes a fault returned by Inject.
+func (f *FaultBackend) RemoveFault(fault *Fault) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i, other := range f.faults {
+		if other == fault {
+			f.faults = append(f.faults[:i], f.faults[i+1:]...)
+			return
+		}
+	}
+}
+
+// Reset removes every fault.
+func (f *FaultBackend) Reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.faults = nil
+}
+
+// FailOn makes the operation fail with the given error on the paths matching
+// the pattern.
+func (f *FaultBackend) FailOn(op, pattern string, err error) *Fault {
+	return f.Inject(Fault{Op: op, Path: pattern, Err: err})
+}
+
+// NoSpaceAfter makes writes to the paths matching the pattern fail as on a full
+// disk once the given number of bytes were written.
+func (f *FaultBackend) NoSpaceAfter(pattern string, n int64) *Fault {
+	return f.Inject(Fault{Op: OpWrite, Path: pattern, AfterBytes: n})
+}
+
+// SlowReads delays every read of the paths matching the pattern.
+func (f *FaultBackend) SlowReads(pattern string, d time.Duration) *Fault {
+	return f.Inject(Fault{Op: OpRead, Path: pattern, Delay: d})
+}
+
+// ShortWrites limits every write to the paths matching the pattern to n bytes.
+func (f *FaultBackend) ShortWrites(pattern string, n int) *Fault {
+	return f.Inject(Fault{Op: OpWrite, Path: pattern, ShortWrite: n})
+}
+
+// CrashOnRename crashes in the middle of renaming a path matching the
+// pattern. For files, the destination is written as a copy of the source,
+// which is left in place, as when a move between devices is interrupted.
+func (f *FaultBackend) CrashOnRename(pattern string) *Fault {
+	return f.Inject(Fault{Op: OpRename, Path: pattern, Crash: true})
+}
+
+// match returns the first fault for the operation on the path, counting it as
+// triggered, or nil if there is none.
+func (f *FaultBackend) match(op, p string) *Fault {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, fault := range f.faults {
+		if fault.Op != "" && fault.Op != op {
+			continue
+		}
+		if fault.Path != "" && !ForceMatch(ToSlashPath(p), fault.Path) {
+			continue
+		}
+		if fault.Times > 0 && fault.triggered >= fault.Times {
+			continue
+		}
+		fault.triggered++
+		return fault
+	}
+	return nil
+}
+
+// inject runs the fault for an operation on the path, if any. It returns the
+// error the operation must fail with.
+func (f *FaultBackend) inject(op, p string) error {
+	fault := f.match(op, p)
+	if fault == nil {
+		return nil
+	}
+	time.Sleep(fault.Delay)
+	if fault.Crash {
+		panic(&os.PathError{Op: op, Path: p, Err: ErrCrashed})
+	}
+	if fault.Err != nil && fault.AfterBytes == 0 {
+		return &os.PathError{Op: op, Path: p, Err: fault.Err}
+	}
+	return nil
+}
+
+// transfer returns the fault for a read or write of n bytes on the path, how
+// many of them can be transferred, and the error to fail with after them.
+func (f *FaultBackend) transfer(op, p string, n int) (*Fault, int, error) {
+	fault := f.match(op, p)
+	if fault == nil {
+		return nil, n, nil
+	}
+	time.Sleep(fault.Delay)
+	if fault.Crash {
+		panic(&os.PathError{Op: op, Path: p, Err: ErrCrashed})
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	allowed, err := n, fault.Err
+	if fault.AfterBytes == 0 && err != nil {
+		allowed = 0
+	} else if fault.AfterBytes > 0 {
+		allowed = int(min(int64(n), max(fault.AfterBytes-fault.bytes, 0)))
+		if allowed == n {
+			err = nil
+		} else if err == nil && op == OpWrite {
+			err = noSpaceErrors[0]
+		} else if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+	}
+	if op == OpWrite && fault.ShortWrite > 0 && allowed > fault.ShortWrite {
+		allowed, err = fault.ShortWrite, io.ErrShortWrite
+	}
+	if err != nil && err != io.ErrShortWrite {
+		err = &os.PathError{Op: op, Path: p, Err: err}
+	}
+	return fault, allowed, err
+}
+
+// record counts the bytes transferred under a fault returned by transfer,
+// reporting whether they used up its AfterBytes.
+func (f *FaultBackend) record(fault *Fault, n int) bool {
+	if fault == nil {
+		return false
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	fault.bytes += int64(n)
+	return fault.AfterBytes > 0 && fault.bytes >= fault.AfterBytes
+}
+
+func (f *FaultBackend) Open(name string) (File, error) {
+	if err := f.inject(OpOpen, name); err != nil {
+		return nil, err
+	}
+	file, err := f.backend.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &faultFile{File: file, faults: f}, nil
+}
+
+func (f *FaultBackend) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	if err := f.inject(OpOpen, name); err != nil {
+		return nil, err
+	}
+	file, err := f.backend.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &faultFile{File: file, faults: f}, nil
+}
+
+func (f *FaultBackend) Stat(name string) (os.FileInfo, error) {
+	if err := f.inject(OpStat, name); err != nil {
+		return nil, err
+	}
+	return f.backend.Stat(name)
+}
+
+func (f *FaultBackend) Lstat(name string) (os.FileInfo, error) {
+	if err := f.inject(OpStat, name); err != nil {
+		return nil, err
+	}
+	return f.backend.Lstat(name)
+}
+
+func (f *FaultBackend) ReadDir(name string) ([]os.DirEntry, error) {
+	if err := f.inject(OpReadDir, name); err != nil {
+		return nil, err
+	}
+	return f.backend.ReadDir(name)
+}
+
+func (f *FaultBackend) MkdirAll(name string, perm os.FileMode) error {
+	if err := f.inject(OpMkdir, name); err != nil {
+		return err
+	}
+	return f.backend.MkdirAll(name, perm)
+}
+
+func (f *FaultBackend) Remove(name string) error {
+	if err := f.inject(OpRemove, name); err != nil {
+		return err
+	}
+	return f.backend.Remove(name)
+}
+
+func (f *FaultBackend) RemoveAll(name string) error {
+	if err := f.inject(OpRemove, name); err != nil {
+		return err
+	}
+	return f.backend.RemoveAll(name)
+}
+
+func (f *FaultBackend) Rename(oldname, newname string) error {
+	fault := f.match(OpRename, oldname)
+	if fault == nil {
+		return f.backend.Rename(oldname, newname)
+	}
+	time.Sleep(fault.Delay)
+	if fault.Crash {
+		f.copyFile(oldname, newname)
+		panic(&os.PathError{Op: OpRename, Path: oldname, Err: ErrCrashed})
+	}
+	if fault.Err != nil {
+		return &os.LinkError{Op: OpRename, Old: oldname, New: newname, Err: fault.Err}
+	}
+	return f.backend.Rename(oldname, newname)
+}
+
+// copyFile copies a file with the wrapped backend, ignoring any error, to
+// leave a rename half done.
+func (f *FaultBackend) copyFile(src, dst string) {
+	info, err := f.backend.Stat(src)
+	if err != nil || info.IsDir() {
+		return
+	}
+	srcFile, err := f.backend.Open(src)
+	if err != nil {
+		return
+	}
+	defer srcFile.Close()
+	dstFile, err := f.backend.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode())
+	if err != nil {
+		return
+	}
+	defer dstFile.Close()
+	io.Copy(dstFile, srcFile)
+}
+
+func (f *FaultBackend) Chmod(name string, mode os.FileMode) error {
+	if err := f.inject(OpChmod, name); err != nil {
+		return err
+	}
+	return f.backend.Chmod(name, mode)
+}
+
+func (f *FaultBackend) Chown(name string, uid, gid int) error {
+	if err := f.inject(OpChown, name); err != nil {
+		return err
+	}
+	return f.backend.Chown(name, uid, gid)
+}
+
+func (f *FaultBackend) Link(oldname, newname string) error {
+	if err := f.inject(OpLink, newname); err != nil {
+		return err
+	}
+	return f.backend.Link(oldname, newname)
+}
+
+func (f *FaultBackend) Symlink(oldname, newname string) error {
+	if err := f.inject(OpSymlink, newname); err != nil {
+		return err
+	}
+	return f.backend.Symlink(oldname, newname)
+}
+
+func (f *FaultBackend) Readlink(name string) (string, error) {
+	if err := f.inject(OpReadlink, name); err != nil {
+		return "", err
+	}
+	return f.backend.Readlink(name)
+}
+
+func (f *FaultBackend) Truncate(name string, size int64) error {
+	if err := f.inject(OpTruncate, name); err != nil {
+		return err
+	}
+	return f.backend.Truncate(name, size)
+}
+
+// faultFile is a file opened by a FaultBackend, injecting faults into its
+// reads and writes.
+type faultFile struct {
+	File
+	faults *FaultBackend
+}
+
+func (f *faultFile) Read(b []byte) (int, error) {
+	fault, n, err := f.faults.transfer(OpRead, f.Name(), len(b))
+	if n == 0 && err != nil {
+		return 0, err
+	}
+	n, readErr := f.File.Read(b[:n])
+	exhausted := f.faults.record(fault, n)
+	if readErr != nil {
+		return n, readErr
+	}
+	if !exhausted {
+		// Reads may return less than asked, so the error only applies once
+		// the bytes allowed were actually read.
+		return n, nil
+	}
+	return n, err
+}
+
+func (f *faultFile) Write(b []byte) (int, error) {
+	fault, n, err := f.faults.transfer(OpWrite, f.Name(), len(b))
+	n, writeErr := f.File.Write(b[:n])
+	f.faults.record(fault, n)
+	if writeErr != nil {
+		return n, writeErr
+	}
+	return n, err
+}
@@ -0,0 +1,125 @@
+package fs
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// withFaults installs a FaultBackend for the duration of the test.
+func withFaults(t *testing.T) *FaultBackend {
+	t.Helper()
+	faults := NewFaultBackend(OSBackend())
+	SetBackend(faults)
+	t.Cleanup(func() { SetBackend(nil) })
+	return faults
+}
+
+func TestFaultReadAfterBytes(t *testing.T) {
+	dir := t.TempDir()
+	small := filepath.Join(dir, "small")
+	large := filepath.Join(dir, "large")
+	writeTree(t, dir, map[string]string{"small": strings.Repeat("s", 50), "large": strings.Repeat("l", 200)})
+	faults := withFaults(t)
+	faults.Inject(Fault{Op: OpRead, Path: filepath.ToSlash(small), AfterBytes: 100})
+	faults.Inject(Fault{Op: OpRead, Path: filepath.ToSlash(large), AfterBytes: 100})
+
+	if data, err := ReadFile(small); err != nil || len(data) != 50 {
+		t.Errorf("ReadFile(small) = %d bytes, %v, want 50 bytes and no error", len(data), err)
+	}
+	data, err := ReadFile(large)
+	if !errors.Is(err, io.ErrUnexpectedEOF) || len(data) != 100 {
+		t.Errorf("ReadFile(large) = %d bytes, %v, want 100 bytes and io.ErrUnexpectedEOF", len(data), err)
+	}
+}
+
+func TestFaultNoSpaceAfter(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "file")
+	faults := withFaults(t)
+	faults.NoSpaceAfter("**/file", 10)
+
+	if err := WriteFileString(p, "abcde"); err != nil {
+		t.Fatalf("WriteFile() under the limit error = %v", err)
+	}
+	err := WriteFileString(p, "1234567890")
+	if !IsNoSpace(err) {
+		t.Fatalf("WriteFile() over the limit error = %v, want a no space error", err)
+	}
+	SetBackend(nil)
+	// The limit is shared by every write, so only 5 more bytes went through.
+	checkContent(t, p, "12345")
+}
+
+func TestFaultShortWrites(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "file")
+	faults := withFaults(t)
+	faults.ShortWrites("**/file", 4)
+
+	if err := WriteFileString(p, "1234567890"); !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("WriteFile() error = %v, want io.ErrShortWrite", err)
+	}
+	SetBackend(nil)
+	checkContent(t, p, "1234")
+}
+
+func TestFaultSlowReads(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "file")
+	writeTree(t, dir, map[string]string{"file": "content"})
+	faults := withFaults(t)
+	faults.SlowReads("**/file", 50*time.Millisecond)
+
+	start := time.Now()
+	data, err := ReadFileString(p)
+	if err != nil || data != "content" {
+		t.Fatalf("ReadFile() = %q, %v, want %q", data, err, "content")
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("ReadFile() took %v, want at least 50ms", elapsed)
+	}
+}
+
+func TestFaultCrashOnRename(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+	writeTree(t, dir, map[string]string{"src": "content"})
+	faults := withFaults(t)
+	faults.CrashOnRename("**/src")
+
+	func() {
+		defer func() {
+			err, _ := recover().(error)
+			if !errors.Is(err, ErrCrashed) {
+				t.Errorf("Rename() panicked with %v, want ErrCrashed", err)
+			}
+		}()
+		Rename(src, dst)
+	}()
+	SetBackend(nil)
+	checkContent(t, src, "content")
+	checkContent(t, dst, "content")
+}
+
+func TestFaultTimes(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "file")
+	writeTree(t, dir, map[string]string{"file": "content"})
+	faults := withFaults(t)
+	faults.Inject(Fault{Op: OpStat, Path: "**/file", Err: syscall.EBUSY, Times: 2})
+
+	for i := range 2 {
+		if _, err := GetInfo(p); !errors.Is(err, syscall.EBUSY) {
+			t.Errorf("GetInfo() call %d error = %v, want EBUSY", i+1, err)
+		}
+	}
+	if _, err := GetInfo(p); err != nil {
+		t.Errorf("GetInfo() after the fault error = %v", err)
+	}
+}
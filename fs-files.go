@@ -2,9 +2,11 @@ package fs
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"hash"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -16,7 +18,7 @@ import (
 // false if it contains data or if there was an error. If the path points to a
 // directory or does not exist, it returns an appropriate error.
 func isEmptyFile(p string) (bool, error) {
-	info, err := os.Stat(p)
+	info, err := GetBackend().Stat(p)
 	if err != nil {
 		return false, err
 	}
@@ -27,28 +29,32 @@ func isEmptyFile(p string) (bool, error) {
 }
 
 // copyFile copies a file from src to dst. If dst does not exist, it will be
-// created. If it exists, it will be overwritten.
-func copyFile(src, dst string) error {
-	srcFile, err := os.Open(src)
+// created. If it exists, it will be overwritten, unless the context is done
+// before it is opened.
+func copyFile(ctx context.Context, src, dst string) error {
+	srcFile, err := GetBackend().Open(src)
 	if err != nil {
 		return err
 	}
 	defer srcFile.Close()
 
-	dstFile, err := os.Create(dst)
+	if err := contextError(ctx, src); err != nil {
+		return err
+	}
+	dstFile, err := GetBackend().OpenFile(dst, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
 		return err
 	}
 	defer dstFile.Close()
 
-	_, err = srcFile.WriteTo(dstFile)
+	_, err = io.Copy(dstFile, newContextReader(ctx, srcFile, src))
 	return err
 }
 
 // sizeFile returns the size of the file at the specified path in bytes. If the
 // path points to a directory or does not exist, it returns an error.
 func sizeFile(p string) (int64, error) {
-	info, err := os.Stat(p)
+	info, err := GetBackend().Stat(p)
 	if err != nil {
 		return 0, err
 	}
@@ -57,19 +63,22 @@ func sizeFile(p string) (int64, error) {
 
 // hashFile computes the hash of the file at the specified path using the
 // provided hash function and returns it as a hexadecimal string.
-func hashFile(p string, h hash.Hash) (string, error) {
-	data, err := ReadFile(p)
+func hashFile(ctx context.Context, p string, h hash.Hash) (string, error) {
+	f, err := GetBackend().Open(p)
 	if err != nil {
 		return "", err
 	}
-	h.Write(data)
+	defer f.Close()
+	if _, err := io.Copy(h, newContextReader(ctx, f, p)); err != nil {
+		return "", err
+	}
 	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
 // IsFile checks if the given path is a file. If the path does not exist or is
 // a directory, it returns false.
 func IsFile(p string) bool {
-	info, err := os.Stat(p)
+	info, err := GetBackend().Stat(p)
 	if err != nil {
 		return false
 	}
@@ -86,10 +95,10 @@ func IsFile(p string) bool {
 // This function is not recursive; it only lists entries in the specified
 // directory, not in its subdirectories.
 func ListFiles(p string) ([]string, error) {
-	entries, err := os.ReadDir(p)
+	entries, err := GetBackend().ReadDir(p)
 	files := []string{}
 	if err != nil {
-		return files, err
+		return files, newError("ListFiles", p, err)
 	}
 	for _, entry := range entries {
 		if !entry.IsDir() {
@@ -117,11 +126,11 @@ func ForceListFiles(p string) []string {
 // and all its subdirectories.
 func ListFilesRecursive(p string) ([]string, error) {
 	if !IsDir(p) {
-		return nil, ErrNotDir
+		return nil, newError("ListFilesRecursive", p, notDirError(p))
 	}
 
 	results := []string{}
-	err := filepath.WalkDir(p, func(path string, d os.DirEntry, err error) error {
+	err := walkDir(p, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
@@ -135,7 +144,7 @@ func ListFilesRecursive(p string) ([]string, error) {
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		return nil, newError("ListFilesRecursive", p, err)
 	}
 	return results, nil
 }
@@ -149,7 +158,8 @@ func ForceListFilesRecursive(p string) []string {
 
 // ReadFile reads the entire content of a file and returns it as a byte slice.
 func ReadFile(p string) ([]byte, error) {
-	return os.ReadFile(p)
+	data, err := readFile(p)
+	return data, newError("ReadFile", p, err)
 }
 
 // ForceReadFile is like ReadFile but ignores any error and returns an empty
@@ -165,7 +175,7 @@ func ForceReadFile(p string) []byte {
 // ReadFileString reads the entire content of a file and returns it as a string.
 func ReadFileString(p string) (string, error) {
 	data, err := ReadFile(p)
-	return string(data), err
+	return string(data), newError("ReadFileString", p, err)
 }
 
 // ForceReadFileString is like ReadFileString but ignores any error and returns
@@ -180,7 +190,7 @@ func ForceReadFileString(p string) string {
 func ReadFileLines(p string) ([]string, error) {
 	data, err := ReadFile(p)
 	if err != nil {
-		return []string{}, err
+		return []string{}, newError("ReadFileLines", p, err)
 	}
 	return strings.Split(string(data), "\n"), nil
 }
@@ -196,10 +206,10 @@ func ForceReadFileLines(p string) []string {
 // variable v, which should be a pointer to the desired data structure.
 func ReadFileJson(p string, v any) error {
 	data, err := ReadFile(p)
-	if err != nil {
-		return err
+	if err == nil {
+		err = json.Unmarshal(data, v)
 	}
-	return json.Unmarshal(data, v)
+	return newError("ReadFileJson", p, err)
 }
 
 // ReadFileJsonAs reads a JSON file and unmarshals its content into a variable
@@ -208,7 +218,7 @@ func ReadFileJson(p string, v any) error {
 func ReadFileJsonAs[T any](p string) (T, error) {
 	var v T
 	err := ReadFileJson(p, &v)
-	return v, err
+	return v, newError("ReadFileJsonAs", p, err)
 }
 
 // ForceReadFileJson is like ReadFileJson but ignores any error. It returns a zero-value
@@ -221,15 +231,29 @@ func ForceReadFileJsonAs[T any](p string) T {
 // WriteFile writes the given byte slice data to a file at the specified path.
 // IF the directory does not exist, it will fail. If the file exists, it will
 // be overwritten.
+//
+// Use WriteFileContext to pass options.
 func WriteFile(p string, data []byte) error {
-	return os.WriteFile(p, data, 0644)
+	return WriteFileContext(context.Background(), p, data)
+}
+
+// WriteFileContext is like WriteFile, but accepts options. The file is left
+// untouched if the context is done before writing it, and retries stop once
+// the context is done; both fail with an error wrapping the context error.
+func WriteFileContext(ctx context.Context, p string, data []byte, opts ...Option) error {
+	o := newContextOptions(ctx, opts)
+	return newError("WriteFile", p, audit("WriteFile", []string{p}, int64(len(data)), func() error {
+		return o.do(func() error {
+			return writeFile(p, data, 0644)
+		})
+	}))
 }
 
 // WriteFileString writes the given string data to a file at the specified path.
 // If the directory does not exist, it will fail. If the file exists, it will
 // be overwritten.
 func WriteFileString(p string, data string) error {
-	return WriteFile(p, []byte(data))
+	return newError("WriteFileString", p, WriteFile(p, []byte(data)))
 }
 
 // WriteFileLines writes the given slice of strings to a file at the specified
@@ -237,7 +261,7 @@ func WriteFileString(p string, data string) error {
 // does not exist, it will fail. If the file exists, it will be overwritten.
 func WriteFileLines(p string, lines []string) error {
 	data := strings.Join(lines, "\n")
-	return WriteFileString(p, data)
+	return newError("WriteFileLines", p, WriteFile(p, []byte(data)))
 }
 
 // WriteFileJson marshals the given variable v into JSON format and writes it to
@@ -245,28 +269,30 @@ func WriteFileLines(p string, lines []string) error {
 // If the file exists, it will be overwritten.
 func WriteFileJson(p string, v any) error {
 	data, err := json.MarshalIndent(v, "", "  ")
-	if err != nil {
-		return err
+	if err == nil {
+		err = WriteFile(p, data)
 	}
-	return WriteFile(p, data)
+	return newError("WriteFileJson", p, err)
 }
 
 // AppendFile appends the given byte slice data to a file at the specified path.
 // If the file does not exist, it will be created.
 func AppendFile(p string, data []byte) error {
-	f, err := os.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	if err != nil {
+	return newError("AppendFile", p, audit("AppendFile", []string{p}, int64(len(data)), func() error {
+		f, err := GetBackend().OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = f.Write(data)
 		return err
-	}
-	defer f.Close()
-	_, err = f.Write(data)
-	return err
+	}))
 }
 
 // AppendFileString appends the given string data to a file at the specified path.
 // If the file does not exist, it will be created.
 func AppendFileString(p string, data string) error {
-	return AppendFile(p, []byte(data))
+	return newError("AppendFileString", p, AppendFile(p, []byte(data)))
 }
 
 // AppendFileLines appends the given slice of strings to a file at the specified
@@ -278,7 +304,7 @@ func AppendFileLines(p string, lines []string) error {
 	if Exists(p) {
 		data = "\n" + data
 	}
-	return AppendFileString(p, data)
+	return newError("AppendFileLines", p, AppendFile(p, []byte(data)))
 }
 
 // AppendFileJson appends the JSON representation of the given variable v to a
@@ -288,13 +314,13 @@ func AppendFileLines(p string, lines []string) error {
 func AppendFileJson(p string, v any) error {
 	data, err := json.Marshal(v)
 	if err != nil {
-		return err
+		return newError("AppendFileJson", p, err)
 	}
 	str := string(data)
 	if Exists(p) {
 		str = "\n" + str
 	}
-	return AppendFile(p, []byte(str))
+	return newError("AppendFileJson", p, AppendFile(p, []byte(str)))
 }
 
 // TouchFile creates an empty file at the specified path if it does not already
@@ -303,11 +329,11 @@ func TouchFile(p string) error {
 	if Exists(p) {
 		return nil
 	}
-	f, err := os.Create(p)
+	f, err := GetBackend().OpenFile(p, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
 	if err != nil {
-		return err
+		return newError("TouchFile", p, err)
 	}
-	return f.Close()
+	return newError("TouchFile", p, f.Close())
 }
 
 // EnsureFile ensures that a file exists at the specified path. It follows
@@ -319,7 +345,7 @@ func TouchFile(p string) error {
 //     and then creates an empty file at the specified path.
 func EnsureFile(p string) error {
 	if IsDir(p) {
-		return ErrIsDir
+		return newError("EnsureFile", p, ErrIsDir)
 	}
 	if Exists(p) {
 		return nil
@@ -328,10 +354,10 @@ func EnsureFile(p string) error {
 	dir := filepath.Dir(p)
 	err := EnsureDir(dir)
 	if err != nil {
-		return err
+		return newError("EnsureFile", p, err)
 	}
 
-	return TouchFile(p)
+	return newError("EnsureFile", p, TouchFile(p))
 }
 
 // ReplaceInFile reads the content of the file at the specified path, replaces
@@ -341,19 +367,19 @@ func EnsureFile(p string) error {
 func ReplaceInFile(p string, old []byte, new []byte) error {
 	data, err := ReadFile(p)
 	if err != nil {
-		return err
+		return newError("ReplaceInFile", p, err)
 	}
 	if !bytes.Contains(data, old) {
 		return nil
 	}
 	modified := bytes.ReplaceAll(data, old, new)
-	return WriteFile(p, modified)
+	return newError("ReplaceInFile", p, WriteFile(p, modified))
 }
 
 // ReplaceInFileString is like ReplaceInFile but works with strings instead of
 // byte slices.
 func ReplaceInFileString(p string, old string, new string) error {
-	return ReplaceInFile(p, []byte(old), []byte(new))
+	return newError("ReplaceInFileString", p, ReplaceInFile(p, []byte(old), []byte(new)))
 }
 
 // CreateTempFile creates a temporary file with the specified prefix in the system's
@@ -361,7 +387,7 @@ func ReplaceInFileString(p string, old string, new string) error {
 func CreateTempFile(prefix string) (string, error) {
 	f, err := os.CreateTemp("", prefix)
 	if err != nil {
-		return "", err
+		return "", newError("CreateTempFile", prefix, err)
 	}
 	f.Close()
 	return f.Name(), nil
@@ -377,15 +403,25 @@ func ForceCreateTempFile(prefix string) string {
 // CreateTempFileOpen creates a temporary file with the specified prefix in the
 // system's default temporary directory and returns an open file handle to it.
 func CreateTempFileOpen(prefix string) (*os.File, error) {
-	return os.CreateTemp("", prefix)
+	f, err := os.CreateTemp("", prefix)
+	if err != nil {
+		return nil, newError("CreateTempFileOpen", prefix, err)
+	}
+	return f, nil
 }
 
 // TruncateFile truncates the file at the specified path to the given size in
-// bytes. If the path points to a directory or does not exist, it returns an
-// error.
+// bytes. If the path points to a directory, it returns an error wrapping
+// ErrIsDir, and if it does not exist, an error wrapping ErrNotExist.
 func TruncateFile(p string, size int64) error {
-	if !IsFile(p) {
-		return ErrIsDir
-	}
-	return os.Truncate(p, size)
+	return newError("TruncateFile", p, audit("TruncateFile", []string{p}, -1, func() error {
+		info, err := GetBackend().Stat(p)
+		if err == nil && info.IsDir() {
+			err = ErrIsDir
+		}
+		if err == nil {
+			err = GetBackend().Truncate(p, size)
+		}
+		return err
+	}))
 }
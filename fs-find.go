@@ -0,0 +1,172 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Boundary decides where FindUpUntil and FindAllUpUntil stop walking up. It
+// receives the directory the search started from and the directory just
+// searched, and returns true if the search must not continue to its parent.
+type Boundary func(start, dir string) bool
+
+// StopAt stops the search after searching the given directory. If the search
+// does not pass through it, it stops at the file system root as usual.
+func StopAt(dir string) Boundary {
+	stop := filepath.Clean(ForceAbsolutePath(dir))
+	return func(start, dir string) bool {
+		return dir == stop
+	}
+}
+
+// StopAtHome stops the search after searching the home directory of the
+// current user.
+func StopAtHome() Boundary {
+	return StopAt(ForceGetHomeDir())
+}
+
+// StopAtDevice stops the search at file system boundaries, after searching the
+// mount point of the file system the search started in.
+func StopAtDevice() Boundary {
+	return func(start, dir string) bool {
+		return !isSameDevice(start, GetPathParent(dir))
+	}
+}
+
+// FindUp searches the start directory and each of its parents, up to the file
+// system root, for a file or directory matching any of the given names or glob
+// patterns. It returns the path of the first match; within a directory, names
+// are tried in the given order. If start is a file, the search starts in its
+// directory. If nothing matches, it returns an error wrapping ErrNotExist.
+//
+// Example: FindUp(".", "go.mod") finds the nearest go.mod file.
+func FindUp(start string, names ...string) (string, error) {
+	p, err := FindUpUntil(start, nil, names...)
+	return p, newError("FindUp", start, err)
+}
+
+// ForceFindUp is like FindUp but ignores any errors and returns an empty
+// string in such cases.
+func ForceFindUp(start string, names ...string) string {
+	p, _ := FindUp(start, names...)
+	return p
+}
+
+// FindUpUntil is like FindUp, but stops walking up at the given boundary, such
+// as StopAtHome() or StopAtDevice(). A nil boundary searches up to the file
+// system root.
+func FindUpUntil(start string, stop Boundary, names ...string) (string, error) {
+	var found string
+	err := findUp(start, stop, names, func(matches []string) bool {
+		found = matches[0]
+		return false
+	})
+	if err == nil && found == "" {
+		err = ErrNotExist
+	}
+	if err != nil {
+		return "", newError("FindUpUntil", start, err)
+	}
+	return found, nil
+}
+
+// FindAllUp is like FindUp, but returns every match, from the nearest to the
+// farthest directory. If nothing matches, it returns an empty slice.
+func FindAllUp(start string, names ...string) ([]string, error) {
+	matches, err := FindAllUpUntil(start, nil, names...)
+	return matches, newError("FindAllUp", start, err)
+}
+
+// ForceFindAllUp is like FindAllUp but ignores any errors and returns an
+// empty slice in such cases.
+func ForceFindAllUp(start string, names ...string) []string {
+	matches, _ := FindAllUp(start, names...)
+	return matches
+}
+
+// FindAllUpUntil is like FindAllUp, but stops walking up at the given
+// boundary. A nil boundary searches up to the file system root.
+func FindAllUpUntil(start string, stop Boundary, names ...string) ([]string, error) {
+	found := []string{}
+	err := findUp(start, stop, names, func(matches []string) bool {
+		found = append(found, matches...)
+		return true
+	})
+	if err != nil {
+		return []string{}, newError("FindAllUpUntil", start, err)
+	}
+	return found, nil
+}
+
+// findUp walks from start toward the root, calling found with the matches of
+// each directory that has any, until it returns false or the boundary is
+// reached.
+func findUp(start string, stop Boundary, names []string, found func(matches []string) bool) error {
+	for _, name := range names {
+		if !IsPatternValid(name) {
+			return ErrInvalid
+		}
+	}
+
+	dir, err := GetParentDir(start)
+	if err != nil {
+		return err
+	}
+	first := dir
+	for {
+		matches := findInDir(dir, names)
+		if len(matches) > 0 && !found(matches) {
+			return nil
+		}
+		if stop != nil && stop(first, dir) {
+			return nil
+		}
+		parent := GetPathParent(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// findInDir returns the paths in dir matching the given names or patterns, in
+// the order of the names.
+func findInDir(dir string, names []string) []string {
+	matches := []string{}
+	for _, name := range names {
+		if !strings.ContainsAny(name, "*?[{") {
+			p := filepath.Join(dir, name)
+			if Exists(p) && !slices.Contains(matches, p) {
+				matches = append(matches, p)
+			}
+			continue
+		}
+		globbed := ForceGlob(dir, name)
+		slices.Sort(globbed)
+		for _, rel := range globbed {
+			p := filepath.Join(dir, rel)
+			if !slices.Contains(matches, p) {
+				matches = append(matches, p)
+			}
+		}
+	}
+	return matches
+}
+
+// isSameDevice reports whether two paths are on the same file system. If the
+// device cannot be determined, it compares the volume names.
+func isSameDevice(p1, p2 string) bool {
+	s1, err1 := os.Stat(p1)
+	s2, err2 := os.Stat(p2)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	dev1, _, ok1 := getFileID(s1)
+	dev2, _, ok2 := getFileID(s2)
+	if ok1 && ok2 {
+		return dev1 == dev2
+	}
+	return GetPathVolume(ForceAbsolutePath(p1)) == GetPathVolume(ForceAbsolutePath(p2))
+}
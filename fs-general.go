@@ -1,15 +1,18 @@
 package fs
 
 import (
+	"context"
 	"crypto/md5"
 	"crypto/sha1"
 	"crypto/sha256"
+	"errors"
 	"hash"
+	iofs "io/fs"
 	"os"
+	"path"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"syscall"
 	"time"
 
 	"github.com/bmatcuk/doublestar/v4"
@@ -17,8 +20,8 @@ import (
 
 // Exists checks if a file or directory exists at the given p.
 func Exists(p string) bool {
-	_, err := os.Stat(p)
-	return !os.IsNotExist(err)
+	_, err := GetBackend().Stat(p)
+	return !errors.Is(err, ErrNotExist)
 }
 
 // IsEmpty checks if a file or directory at the specified path is empty.
@@ -27,11 +30,17 @@ func Exists(p string) bool {
 // If the path does not exist, it returns an error but also true.
 func IsEmpty(p string) (bool, error) {
 	if IsDir(p) {
-		return isEmptyDir(p)
+		empty, err := isEmptyDir(p)
+		return empty, newError("IsEmpty", p, err)
 	} else if IsFile(p) {
-		return isEmptyFile(p)
+		empty, err := isEmptyFile(p)
+		return empty, newError("IsEmpty", p, err)
 	} else {
-		return true, os.ErrNotExist
+		_, err := GetBackend().Stat(p)
+		if err == nil {
+			err = ErrNotExist
+		}
+		return true, newError("IsEmpty", p, err)
 	}
 }
 
@@ -44,69 +53,36 @@ func ForceIsEmpty(p string) bool {
 // IsFile checks if two files or directories at the specified paths refer to the same file
 // or directory.
 func IsSame(p1, p2 string) bool {
-	s1, err := os.Stat(p1)
+	s1, err := GetBackend().Stat(p1)
 	if err != nil {
 		return false
 	}
-	s2, err := os.Stat(p2)
+	s2, err := GetBackend().Stat(p2)
 	if err != nil {
 		return false
 	}
 	return os.SameFile(s1, s2)
 }
 
-// IsExecutable checks if a file at the specified path is executable. Directories
-// are considered not executable.
+// IsExecutable checks if a file at the specified path can be executed by the
+// current user. Directories are considered not executable. See Access for
+// details.
 func IsExecutable(p string) bool {
-	if !IsFile(p) {
-		return false
-	}
-	info, err := os.Stat(p)
-	if err != nil {
-		return false
-	}
-	mode := info.Mode()
-	if mode&0111 != 0 {
-		return true
-	}
-	if runtime.GOOS == "windows" {
-		ext := strings.ToLower(filepath.Ext(p))
-		pathext := os.Getenv("PATHEXT")
-		for _, e := range strings.Split(pathext, ";") {
-			if strings.ToLower(e) == ext {
-				return true
-			}
-		}
-	}
-	return false
+	return IsFile(p) && Access(p, AccessExecute) == nil
 }
 
-// IsReadable checks if a file at the specified path is readable. Directories
-// are considered not readable.
+// IsReadable checks if a file at the specified path can be read by the current
+// user, without opening it. Directories are considered not readable. See
+// Access to check directories.
 func IsReadable(p string) bool {
-	if !IsFile(p) {
-		return false
-	}
-	file, err := os.OpenFile(p, os.O_RDONLY, 0)
-	if err != nil {
-		return false
-	}
-	file.Close()
-	return true
+	return IsFile(p) && Access(p, AccessRead) == nil
 }
 
-// IsWritable checks if a file at the specified path is writable. Directories
-// are considered not writable.
+// IsWritable checks if a file at the specified path can be written by the
+// current user, without opening it. Directories are considered not writable.
+// See Access to check directories.
 func IsWritable(p string) bool {
-	if !IsFile(p) {
-		return false
-	}
-	file, err := os.OpenFile(p, os.O_WRONLY, 0)
-	if err != nil {
-		return false
-	}
-	file.Close()
-	return true
+	return IsFile(p) && Access(p, AccessWrite) == nil
 }
 
 // IsHidden checks if a file or directory at the specified path is hidden.
@@ -120,15 +96,8 @@ func IsHidden(p string) (bool, error) {
 	abs := Force(AbsolutePath(p))
 	base := filepath.Base(p)
 	if runtime.GOOS == "windows" {
-		pointer, err := syscall.UTF16PtrFromString(abs)
-		if err != nil {
-			return false, err
-		}
-		attributes, err := syscall.GetFileAttributes(pointer)
-		if err != nil {
-			return false, err
-		}
-		return attributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0, nil
+		hidden, err := getHiddenAttribute(abs)
+		return hidden, newError("IsHidden", p, err)
 	}
 	return strings.HasPrefix(base, "."), nil
 }
@@ -150,9 +119,9 @@ func IsPatternValid(pattern string) bool {
 // the relative path of the files or directories found as its argument. If the callback
 // function returns an error, the walk is aborted and the error is returned.
 func Walk(p string, fn func(string) error) error {
-	return filepath.WalkDir(p, func(path string, d os.DirEntry, err error) error {
+	return walkDir(p, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
-			return err
+			return newError("Walk", path, err)
 		}
 		return fn(ForceRelativePath(p, path))
 	})
@@ -166,10 +135,10 @@ func Walk(p string, fn func(string) error) error {
 // This function is not recursive; it only lists entries in the specified
 // directory, not in its subdirectories.
 func List(p string) ([]string, error) {
-	entries, err := os.ReadDir(p)
+	entries, err := GetBackend().ReadDir(p)
 	files := []string{}
 	if err != nil {
-		return files, err
+		return files, newError("List", p, err)
 	}
 	names := make([]string, len(entries))
 	for i, entry := range entries {
@@ -193,12 +162,21 @@ func ForceList(p string) []string {
 // This function is recursive; it lists entries in the specified directory
 // and all its subdirectories.
 func ListRecursive(p string) ([]string, error) {
+	return ListRecursiveContext(context.Background(), p)
+}
+
+// ListRecursiveContext is like ListRecursive, but stops walking once the
+// context is done, returning an error wrapping the context error.
+func ListRecursiveContext(ctx context.Context, p string) ([]string, error) {
 	if !IsDir(p) {
-		return nil, ErrNotDir
+		return nil, newError("ListRecursive", p, notDirError(p))
 	}
 
 	results := []string{}
-	err := filepath.WalkDir(p, func(path string, d os.DirEntry, err error) error {
+	err := walkDir(p, func(path string, d os.DirEntry, err error) error {
+		if err := contextError(ctx, path); err != nil {
+			return err
+		}
 		if err != nil {
 			return err
 		}
@@ -212,7 +190,7 @@ func ListRecursive(p string) ([]string, error) {
 		return nil
 	})
 	if err != nil {
-		return nil, err
+		return nil, newError("ListRecursive", p, err)
 	}
 	return results, nil
 }
@@ -229,15 +207,46 @@ func ForceListRecursive(p string) []string {
 // The pattern may describe hierarchical names such as /usr/*/bin/ed (assuming
 // the Separator is '/').
 func Glob(dir, pattern string) ([]string, error) {
-	files, err := doublestar.Glob(nil, filepath.Join(dir, pattern))
+	return GlobContext(context.Background(), dir, pattern)
+}
+
+// GlobContext is like Glob, but stops matching once the context is done,
+// returning an error wrapping the context error.
+func GlobContext(ctx context.Context, dir, pattern string) ([]string, error) {
+	files, err := globContext(ctx, filepath.Join(dir, pattern))
 	if files == nil {
 		files = []string{}
 	}
-	r := len(dir) + len(PathSeparator)
 	for i, f := range files {
-		files[i] = f[r:]
+		files[i] = ForceRelativePath(dir, f)
+	}
+	return files, newError("Glob", filepath.Join(dir, pattern), err)
+}
+
+// globContext is like doublestar.FilepathGlob, but checks the context before
+// each match.
+func globContext(ctx context.Context, pattern string) ([]string, error) {
+	base, rest := doublestar.SplitPattern(filepath.ToSlash(filepath.Clean(pattern)))
+	if ctx.Done() == nil || rest == "" || rest == "." || rest == ".." {
+		return doublestar.FilepathGlob(pattern)
+	}
+	if err := contextError(ctx, filepath.FromSlash(base)); err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	err := doublestar.GlobWalk(os.DirFS(base), rest, func(p string, d iofs.DirEntry) error {
+		match := filepath.FromSlash(path.Join(base, p))
+		if err := contextError(ctx, match); err != nil {
+			return err
+		}
+		files = append(files, match)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return files, err
+	return files, nil
 }
 
 // ForceGlob is like Glob but ignores any errors and returns an empty slice in
@@ -250,7 +259,8 @@ func ForceGlob(dir string, pattern string) []string {
 // Match reports whether the path matches the given pattern. The pattern syntax
 // is the [doublestar](https://github.com/bmatcuk/doublestar#readme) syntax.
 func Match(p, pattern string) (bool, error) {
-	return doublestar.Match(pattern, p)
+	matched, err := doublestar.Match(pattern, p)
+	return matched, newError("Match", pattern, err)
 }
 
 // ForceMatch is like Match but ignores any errors and returns false in such
@@ -264,39 +274,110 @@ func ForceMatch(p, pattern string) bool {
 // copies the entire directory recursively. If src is a file, it copies the file.
 // If dst does not exist, it will be created. If it exists, it will be merged
 // (for directories) or overwritten (for files).
+//
+// Use CopyContext to pass options.
 func Copy(src, dst string) error {
+	return CopyContext(context.Background(), src, dst)
+}
+
+// CopyContext is like Copy, but stops copying once the context is done, even
+// in the middle of a large file, returning an error wrapping the context error.
+// Destination files are only opened, and truncated, while the context is not
+// done, but a file interrupted in the middle is left partially written.
+//
+// With WithContinueOnError, it copies every file it can and returns a
+// *MultiError listing the ones that could not be copied.
+func CopyContext(ctx context.Context, src, dst string, opts ...Option) error {
 	if IsDir(src) {
-		return copyDir(src, dst)
+		b := newBulk("Copy", newContextOptions(ctx, opts))
+		err := copyDir(src, dst, b)
+		if err == nil {
+			err = b.err()
+		}
+		return newLinkError("Copy", src, dst, err)
 	}
-	return copyFile(src, dst)
+	return newLinkError("Copy", src, dst, auditCopy(newContextOptions(ctx, opts), src, dst))
+}
+
+// auditCopy copies a file as a step of Copy, recording it into the audit log.
+func auditCopy(o options, src, dst string) error {
+	return audit("Copy", []string{src, dst}, -1, func() error {
+		return o.do(func() error {
+			return copyFile(o.ctx, src, dst)
+		})
+	})
 }
 
 // Move moves a file or directory from src to dst. It is equivalent to renaming
 // the file or directory. If src and dst are on different filesystems, it
 // performs a copy followed by a delete of the original.
+//
+// Use MoveContext to pass options.
 func Move(src, dst string) error {
-	return os.Rename(src, dst)
+	return MoveContext(context.Background(), src, dst)
+}
+
+// MoveContext is like Move, but accepts options. Nothing is moved if the
+// context is done before the first attempt, and no retry is made once it is
+// done, failing with an error wrapping the context error.
+func MoveContext(ctx context.Context, src, dst string, opts ...Option) error {
+	o := newContextOptions(ctx, opts)
+	return newLinkError("Move", src, dst, audit("Move", []string{src, dst}, -1, func() error {
+		return o.do(func() error {
+			return GetBackend().Rename(src, dst)
+		})
+	}))
 }
 
 // Rename renames (moves) a file or directory from oldPath to newPath. If oldPath
 // and newPath are on different filesystems, it performs a copy followed by a
 // delete of the original.
+//
+// Use RenameContext to pass options.
 func Rename(oldPath, newPath string) error {
-	return os.Rename(oldPath, newPath)
+	return RenameContext(context.Background(), oldPath, newPath)
+}
+
+// RenameContext is like Rename, but accepts options. Like MoveContext, it
+// gives up with an error wrapping the context error when the context is done
+// before renaming or between retries.
+func RenameContext(ctx context.Context, oldPath, newPath string, opts ...Option) error {
+	o := newContextOptions(ctx, opts)
+	return newLinkError("Rename", oldPath, newPath, audit("Rename", []string{oldPath, newPath}, -1, func() error {
+		return o.do(func() error {
+			return GetBackend().Rename(oldPath, newPath)
+		})
+	}))
 }
 
 // Remove removes a file or directory at the specified path. If the path is a
 // directory, it removes the directory and all its contents recursively.
 // If the path does not exist, it returns nil (no error).
-// If there is an error, it will be of type [*PathError].
+// If there is an error, it will be of type [*Error].
+//
+// Use RemoveContext to pass options.
 func Remove(p string) error {
-	return os.RemoveAll(p)
+	return RemoveContext(context.Background(), p)
+}
+
+// RemoveContext is like Remove, but stops removing entries once the context is
+// done, returning an error wrapping the context error. Entries removed before
+// the cancellation are not restored.
+func RemoveContext(ctx context.Context, p string, opts ...Option) error {
+	o := newContextOptions(ctx, opts)
+	return newError("Remove", p, audit("Remove", []string{p}, -1, func() error {
+		return o.do(func() error {
+			return removeAll(ctx, p)
+		})
+	}))
 }
 
 // SetMode sets the file mode (permissions) of a file at the specified path. If
 // the path does not exist, it returns an error.
 func SetMode(p string, mode os.FileMode) error {
-	return os.Chmod(p, mode)
+	return newError("SetMode", p, audit("SetMode", []string{p}, -1, func() error {
+		return GetBackend().Chmod(p, mode)
+	}))
 }
 
 // SetHidden sets or unsets the hidden attribute of a file or directory at the
@@ -309,20 +390,9 @@ func SetMode(p string, mode os.FileMode) error {
 func SetHidden(p string, hidden bool) error {
 	abs := Force(AbsolutePath(p))
 	if runtime.GOOS == "windows" {
-		pointer, err := syscall.UTF16PtrFromString(abs)
-		if err != nil {
-			return err
-		}
-		attributes, err := syscall.GetFileAttributes(pointer)
-		if err != nil {
-			return err
-		}
-		if hidden {
-			attributes |= syscall.FILE_ATTRIBUTE_HIDDEN
-		} else {
-			attributes &^= syscall.FILE_ATTRIBUTE_HIDDEN
-		}
-		return syscall.SetFileAttributes(pointer, attributes)
+		return newError("SetHidden", p, audit("SetHidden", []string{p}, -1, func() error {
+			return setHiddenAttribute(abs, hidden)
+		}))
 	}
 	base := filepath.Base(p)
 	dir := filepath.Dir(p)
@@ -331,80 +401,102 @@ func SetHidden(p string, hidden bool) error {
 			return nil
 		}
 		newPath := filepath.Join(dir, "."+base)
-		return os.Rename(p, newPath)
+		return newLinkError("SetHidden", p, newPath, auditRename("SetHidden", p, newPath))
 	} else {
 		if !strings.HasPrefix(base, ".") {
 			return nil
 		}
 		newBase := strings.TrimPrefix(base, ".")
 		newPath := filepath.Join(dir, newBase)
-		return os.Rename(p, newPath)
+		return newLinkError("SetHidden", p, newPath, auditRename("SetHidden", p, newPath))
 	}
 }
 
+// auditRename renames a file as a step of op, recording it into the audit log.
+func auditRename(op, oldPath, newPath string) error {
+	return audit(op, []string{oldPath, newPath}, -1, func() error {
+		return GetBackend().Rename(oldPath, newPath)
+	})
+}
+
 // Hide sets the hidden attribute of a file or directory at the specified path.
 // Same as SetHidden with hidden=true.
 func Hide(p string) error {
-	return SetHidden(p, true)
+	return newError("Hide", p, SetHidden(p, true))
 }
 
 // Unhide clears the hidden attribute of a file or directory at the specified path.
 // Same as SetHidden with hidden=false.
 func Unhide(p string) error {
-	return SetHidden(p, false)
+	return newError("Unhide", p, SetHidden(p, false))
 }
 
 // Chmod is an alias for SetMode.
 func Chmod(p string, mode os.FileMode) error {
-	return os.Chmod(p, mode)
+	return newError("Chmod", p, audit("Chmod", []string{p}, -1, func() error {
+		return GetBackend().Chmod(p, mode)
+	}))
 }
 
 // Chown changes the ownership of a file at the specified path to the given
 // user ID (uid) and group ID (gid). If the path does not exist, it returns
 // an error.
 func Chown(p string, uid, gid int) error {
-	return os.Chown(p, uid, gid)
+	return newError("Chown", p, audit("Chown", []string{p}, -1, func() error {
+		return GetBackend().Chown(p, uid, gid)
+	}))
 }
 
 // Chdir changes the current working directory to the specified path. If the
 // path does not exist or is not a directory, it returns an error.
 func Chdir(p string) error {
-	return os.Chdir(p)
+	return newError("Chdir", p, os.Chdir(p))
 }
 
 // SetOwner is an alias for Chown.
 func SetOwner(p string, uid, gid int) error {
-	return os.Chown(p, uid, gid)
+	return newError("SetOwner", p, audit("SetOwner", []string{p}, -1, func() error {
+		return GetBackend().Chown(p, uid, gid)
+	}))
 }
 
 // Empty removes all contents of a file or directory at the specified path. If
 // the path is a directory, it removes all files and subdirectories within it
-// but keeps the directory itself. If the path is a file, it truncates the file
+// but keeps the directory itself. If the path is a file, it truncates the file.
 func Empty(p string) error {
 	if IsDir(p) {
-		return EmptyDir(p)
+		return newError("Empty", p, EmptyDir(p))
 	} else if IsFile(p) {
-		return TruncateFile(p, 0)
+		return newError("Empty", p, TruncateFile(p, 0))
 	} else {
-		return os.ErrNotExist
+		_, err := GetBackend().Stat(p)
+		if err == nil {
+			err = ErrNotExist
+		}
+		return newError("Empty", p, err)
 	}
 }
 
 // Link creates a hard link from src to dst. If src does not exist or dst
 // already exists, it returns an error.
 func Link(src, dst string) error {
-	return os.Link(src, dst)
+	return newLinkError("Link", src, dst, audit("Link", []string{src, dst}, -1, func() error {
+		return GetBackend().Link(src, dst)
+	}))
 }
 
 // Symlink creates a symbolic link from oldname to newname. If oldname does not
 // exist or newname already exists, it returns an error.
 func Symlink(oldname, newname string) error {
-	return os.Symlink(oldname, newname)
+	return newLinkError("Symlink", oldname, newname, audit("Symlink", []string{oldname, newname}, 0, func() error {
+		return GetBackend().Symlink(oldname, newname)
+	}))
 }
 
 // Readlink returns the destination of the named symbolic link.
 func Readlink(p string) (string, error) {
-	return os.Readlink(p)
+	link, err := GetBackend().Readlink(p)
+	return link, newError("Readlink", p, err)
 }
 
 // ForceReadlink is like Readlink but ignores any errors and returns an empty
@@ -418,7 +510,8 @@ func ForceReadlink(p string) string {
 // path is a directory, it computes the hash based on the contents of all files
 // within the directory recursively. It returns the hash as a hexadecimal string.
 func MD5(p string) (string, error) {
-	return Hash(p, md5.New())
+	sum, err := Hash(p, md5.New())
+	return sum, newError("MD5", p, err)
 }
 
 // ForceMD5 is like MD5 but ignores any errors and returns an empty string in
@@ -432,7 +525,8 @@ func ForceMD5(p string) string {
 // the path is a directory, it computes the hash based on the contents of all files
 // within the directory recursively. It returns the hash as a hexadecimal string.
 func SHA1(p string) (string, error) {
-	return Hash(p, sha1.New())
+	sum, err := Hash(p, sha1.New())
+	return sum, newError("SHA1", p, err)
 }
 
 // ForceSHA1 is like SHA1 but ignores any errors and returns an empty string in
@@ -447,7 +541,8 @@ func ForceSHA1(p string) string {
 // files within the directory recursively. It returns the hash as a hexadecimal
 // string.
 func SHA256(path string) (string, error) {
-	return Hash(path, sha256.New())
+	sum, err := Hash(path, sha256.New())
+	return sum, newError("SHA256", path, err)
 }
 
 // ForceSHA256 is like SHA256 but ignores any errors and returns an empty string in
@@ -460,7 +555,8 @@ func ForceSHA256(path string) string {
 // Checksum computes the MD5 checksum of a file or directory at the specified path.
 // Alias for MD5.
 func Checksum(p string) (string, error) {
-	return Hash(p, md5.New())
+	sum, err := Hash(p, md5.New())
+	return sum, newError("Checksum", p, err)
 }
 
 // ForceChecksum is like Checksum but ignores any errors and returns an empty
@@ -474,11 +570,30 @@ func ForceChecksum(p string) string {
 // provided hash.Hash implementation. If the path is a directory, it computes the
 // hash based on the contents of all files within the directory recursively.
 // It returns the hash as a hexadecimal string.
+//
+// The hash of a directory is the hash of the hashes of its entries, in name
+// order, each computed on its own; h is reset along the way. Use HashContext
+// to pass options.
 func Hash(p string, h hash.Hash) (string, error) {
+	return HashContext(context.Background(), p, h)
+}
+
+// HashContext is like Hash, but stops reading once the context is done, even in
+// the middle of a large file, returning an error wrapping the context error.
+//
+// With WithContinueOnError, files that cannot be read are left out of the hash
+// of a directory and listed in the returned *MultiError.
+func HashContext(ctx context.Context, p string, h hash.Hash, opts ...Option) (string, error) {
 	if IsDir(p) {
-		return hashDir(p, h)
+		b := newBulk("Hash", newContextOptions(ctx, opts))
+		sum, err := hashDir(p, h, b)
+		if err == nil {
+			err = b.err()
+		}
+		return sum, newError("Hash", p, err)
 	}
-	return hashFile(p, h)
+	sum, err := hashFile(ctx, p, h)
+	return sum, newError("Hash", p, err)
 }
 
 // ForceHash is like Hash but ignores any errors and returns an empty string in
@@ -491,11 +606,28 @@ func ForceHash(p string, h hash.Hash) string {
 // Size returns the size of a file or directory at the specified path in bytes.
 // If the path is a directory, it computes the total size of all files within
 // the directory recursively. It returns the size in bytes.
+//
+// Use SizeContext to pass options.
 func Size(p string) (int64, error) {
+	return SizeContext(context.Background(), p)
+}
+
+// SizeContext is like Size, but stops walking once the context is done,
+// returning an error wrapping the context error.
+//
+// With WithContinueOnError, files that cannot be read are left out of the size
+// of a directory and listed in the returned *MultiError.
+func SizeContext(ctx context.Context, p string, opts ...Option) (int64, error) {
 	if IsDir(p) {
-		return sizeDir(p)
+		b := newBulk("Size", newContextOptions(ctx, opts))
+		size, err := sizeDir(p, b)
+		if err == nil {
+			err = b.err()
+		}
+		return size, newError("Size", p, err)
 	}
-	return sizeFile(p)
+	size, err := sizeFile(p)
+	return size, newError("Size", p, err)
 }
 
 // ForceSize is like Size but ignores any errors and returns zero in such cases.
@@ -508,9 +640,9 @@ func ForceSize(p string) int64 {
 // Unix timestamp (seconds since January 1, 1970). If the path does not exist
 // or is a directory, it returns an error.
 func GetModTime(p string) (time.Time, error) {
-	info, err := os.Stat(p)
+	info, err := GetBackend().Stat(p)
 	if err != nil {
-		return time.Time{}, err
+		return time.Time{}, newError("GetModTime", p, err)
 	}
 	return info.ModTime(), nil
 }
@@ -525,15 +657,19 @@ func ForceGetModTime(p string) time.Time {
 // GetInfo returns a FileInfo describing the file at the specified path. If the
 // path does not exist, it returns an error.
 func GetInfo(p string) (os.FileInfo, error) {
-	return os.Stat(p)
+	info, err := GetBackend().Stat(p)
+	if err != nil {
+		return nil, newError("GetInfo", p, err)
+	}
+	return info, nil
 }
 
 // GetMode returns the file mode (permissions) of a file at the specified path. If
 // the path does not exist, it returns an error.
 func GetMode(p string) (os.FileMode, error) {
-	info, err := os.Stat(p)
+	info, err := GetBackend().Stat(p)
 	if err != nil {
-		return 0, err
+		return 0, newError("GetMode", p, err)
 	}
 	return info.Mode(), nil
 }
@@ -0,0 +1,84 @@
+package fs
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"path/filepath"
+	"testing"
+)
+
+// The plain functions keep their signatures, so they can be used as values;
+// options go to their Context variants.
+var (
+	_ func(src, dst string) error                 = Copy
+	_ func(src, dst string) error                 = Move
+	_ func(oldPath, newPath string) error         = Rename
+	_ func(p string) error                        = Remove
+	_ func(p string) error                        = EmptyDir
+	_ func(p string) error                        = Empty
+	_ func(p string, data []byte) error           = WriteFile
+	_ func(p string, data string) error           = WriteFileString
+	_ func(p string, lines []string) error        = WriteFileLines
+	_ func(p string, v any) error                 = WriteFileJson
+	_ func(p string) (string, error)              = MD5
+	_ func(p string) (string, error)              = SHA1
+	_ func(p string) (string, error)              = SHA256
+	_ func(p string) (string, error)              = Checksum
+	_ func(p string, h hash.Hash) (string, error) = Hash
+	_ func(p string) (int64, error)               = Size
+)
+
+func TestHashSkipsPartialFiles(t *testing.T) {
+	full := t.TempDir()
+	partial := t.TempDir()
+	for p, content := range map[string]string{
+		filepath.Join(full, "a"):           "alpha",
+		filepath.Join(full, "b"):           "bravo",
+		filepath.Join(full, "sub", "c"):    "charlie",
+		filepath.Join(partial, "a"):        "alpha",
+		filepath.Join(partial, "sub", "c"): "charlie",
+	} {
+		if err := EnsureDir(filepath.Dir(p)); err != nil {
+			t.Fatal(err)
+		}
+		if err := WriteFileString(p, content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := Hash(partial, sha256.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// b fails after part of it was read, which must not leak into the hash.
+	faults := NewFaultBackend(OSBackend())
+	faults.Inject(Fault{Op: OpRead, Path: filepath.ToSlash(filepath.Join(full, "b")), AfterBytes: 2, Err: ErrPermission})
+	SetBackend(faults)
+	defer SetBackend(nil)
+
+	got, err := HashContext(context.Background(), full, sha256.New(), WithContinueOnError())
+	var multi *MultiError
+	if !errors.As(err, &multi) || !errors.Is(err, ErrPermission) {
+		t.Fatalf("Hash() error = %v, want a *MultiError wrapping ErrPermission", err)
+	}
+	if got != want {
+		t.Errorf("Hash() = %s, want %s as if b did not exist", got, want)
+	}
+}
+
+type plainHash struct{ hash.Hash }
+
+func TestHashDirPlainHash(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a": "a", "sub/b": "b"})
+	want, err := Hash(dir, sha256.New())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Hash(dir, plainHash{sha256.New()})
+	if err != nil || got != want {
+		t.Errorf("Hash() with a hash not implementing hash.Cloner = %s, %v, want %s", got, err, want)
+	}
+}
@@ -0,0 +1,158 @@
+package fs
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// WatchOption configures the recursive watchers, WatchRecursive and WatchGlob.
+type WatchOption func(*watchOptions)
+
+type watchOptions struct {
+	exclude   []string
+	gitIgnore bool
+}
+
+// WithExclude excludes the paths matching any of the given patterns from being
+// watched or reported. Patterns use the doublestar syntax and are matched
+// against the path relative to the watched directory, using forward slashes.
+// Patterns without a slash are also matched against the name of each entry,
+// so "node_modules" excludes node_modules directories at any depth.
+//
+// Excluded directories are never added to the watcher.
+func WithExclude(patterns ...string) WatchOption {
+	return func(o *watchOptions) {
+		o.exclude = append(o.exclude, patterns...)
+	}
+}
+
+// WithGitIgnore honors the .gitignore files found in the watched tree,
+// excluding the paths they ignore as WithExclude does. The .git directory is
+// always excluded.
+func WithGitIgnore() WatchOption {
+	return func(o *watchOptions) {
+		o.gitIgnore = true
+	}
+}
+
+// ignoreRule is a single pattern of a .gitignore file.
+type ignoreRule struct {
+	base     string // Directory of the .gitignore file, relative to the root
+	pattern  string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// ignoreMatcher decides which paths of a watched tree are excluded, combining
+// the exclude patterns and the rules of the .gitignore files loaded so far.
+type ignoreMatcher struct {
+	root    string
+	options watchOptions
+	rules   []ignoreRule
+}
+
+// newIgnoreMatcher creates a matcher for the tree rooted at root.
+func newIgnoreMatcher(root string, opts []WatchOption) *ignoreMatcher {
+	m := &ignoreMatcher{root: root}
+	for _, opt := range opts {
+		opt(&m.options)
+	}
+	return m
+}
+
+// load reads the .gitignore file of the given directory, if gitignore support
+// is enabled and the file exists.
+func (m *ignoreMatcher) load(dir string) {
+	if !m.options.gitIgnore {
+		return
+	}
+	lines, err := ReadFileLines(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+
+	base := ToSlashPath(ForceRelativePath(m.root, dir))
+	if base == "." {
+		base = ""
+	}
+	for _, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasSuffix(line, "\\ ") {
+			line = strings.TrimRight(line[:len(line)-2], " ") + " "
+		} else {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		line = strings.TrimPrefix(line, "\\")
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		m.rules = append(m.rules, rule)
+	}
+}
+
+// match reports whether the path, relative to the root, is excluded.
+func (m *ignoreMatcher) match(rel string, isDir bool) bool {
+	rel = ToSlashPath(rel)
+	if rel == "" || rel == "." {
+		return false
+	}
+
+	name := path.Base(rel)
+	for _, pattern := range m.options.exclude {
+		if ForceMatch(rel, pattern) {
+			return true
+		}
+		if !strings.Contains(pattern, "/") && ForceMatch(name, pattern) {
+			return true
+		}
+	}
+
+	if !m.options.gitIgnore {
+		return false
+	}
+	if name == ".git" && isDir {
+		return true
+	}
+
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		sub := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			sub = strings.TrimPrefix(rel, rule.base+"/")
+		}
+		pattern := rule.pattern
+		if !rule.anchored {
+			pattern = "**/" + pattern
+		}
+		if ForceMatch(sub, pattern) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
@@ -0,0 +1,69 @@
+package fs
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":     "# comment\n*.log\n!keep.log\n/build\ndocs/*.tmp\nout/\ntrailing\\ \nspaces   \n\\#hash\n",
+		"sub/.gitignore": "gen/\n/local\n",
+	})
+	m := newIgnoreMatcher(root, []WatchOption{WithGitIgnore(), WithExclude("node_modules", "tmp/**")})
+	m.load(root)
+	m.load(filepath.Join(root, "sub"))
+
+	tests := []struct {
+		rel   string
+		isDir bool
+		want  bool
+	}{
+		{".", true, false},
+		{"a.log", false, true},
+		{"deep/x/a.log", false, true},
+		{"keep.log", false, false},
+		{"deep/keep.log", false, false},
+		{"build", true, true},
+		{"src/build", true, false},
+		{"docs/a.tmp", false, true},
+		{"x/docs/a.tmp", false, false},
+		{"out", true, true},
+		{"x/out", true, true},
+		{"out", false, false},
+		{"trailing ", false, true},
+		{"trailing", false, false},
+		{"spaces", false, true},
+		{"#hash", false, true},
+		{"comment", false, false},
+		{"sub/gen", true, true},
+		{"sub/x/gen", true, true},
+		{"gen", true, false},
+		{"sub/local", false, true},
+		{"sub/x/local", false, false},
+		{"local", false, false},
+		{".git", true, true},
+		{".git", false, false},
+		{"node_modules", true, true},
+		{"a/node_modules", true, true},
+		{"tmp/x", false, true},
+		{"x/tmp/y", false, false},
+		{filepath.Join("deep", "a.log"), false, true},
+	}
+	for _, tt := range tests {
+		if got := m.match(tt.rel, tt.isDir); got != tt.want {
+			t.Errorf("match(%q, %v) = %v, want %v", tt.rel, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestIgnoreMatcherWithoutGitIgnore(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{".gitignore": "*.log\n"})
+	m := newIgnoreMatcher(root, nil)
+	m.load(root)
+	if m.match("a.log", false) || m.match(".git", true) {
+		t.Errorf("match() excluded paths without WithGitIgnore")
+	}
+}
@@ -0,0 +1,240 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// Operation describes a backend operation observed by a Middleware.
+type Operation struct {
+	Name     string        // Backend operation (eg: OpWrite)
+	Paths    []string      // Path of the operation, followed by the destination for renames and links
+	Bytes    int64         // Bytes read or written, for OpRead and OpWrite
+	Start    time.Time     // When the operation started
+	Duration time.Duration // How long the operation took, only set after it
+	Err      error         // Error of the operation, only set after it
+}
+
+// Middleware observes the operations of a backend. Before is called before
+// each operation and After once it completes; either may be nil. With several
+// middlewares, Before hooks run in order and After hooks in reverse order.
+//
+// Before receives the context returned by the Before hook of the previous
+// middleware, starting from context.Background(), and returns the context
+// passed to its own After hook, which links both calls, eg: to start a trace
+// span in Before and end it in After. Returning nil keeps the received context.
+// Backend operations carry no context, so the context given to a Context
+// variant, such as CopyContext, does not reach the hooks.
+//
+// Middlewares observe backend operations, not package functions. Reads and
+// writes are reported per Read and Write call on the open file, whose size is
+// up to the caller (eg: io.Copy uses 32 KiB buffers), so a single ReadFile
+// reports one OpOpen and several OpRead operations, and Copy reports the stats,
+// opens, reads and writes of every file it copies.
+//
+// Functions that do not go through the Backend are not observed: Glob and
+// GlobContext (and so the patterns of FindUp), the Watcher, Watch and
+// WatchFile, Root, Access and AccessReal, Which and its variants, SecureJoin,
+// WaitForStable, StopAtDevice, Chdir, GetCurrentDir, CreateTempDir,
+// CreateTempFile, and the files of AuditLog and transaction journals.
+type Middleware struct {
+	Before func(ctx context.Context, op Operation) context.Context
+	After  func(ctx context.Context, op Operation)
+}
+
+// used is the backend installed by Use, guarded by backendMu.
+var used *middlewareBackend
+
+// Use installs the middlewares on the backend used by the package functions,
+// replacing the ones installed by a previous call. Call it without
+// middlewares to remove them. SetBackend replaces the backend along with its
+// middlewares, so call Use after it.
+func Use(middlewares ...Middleware) {
+	backendMu.Lock()
+	defer backendMu.Unlock()
+	if used != nil && backend == Backend(used) {
+		backend = used.backend
+	}
+	used = nil
+	if len(middlewares) > 0 {
+		used = &middlewareBackend{backend: backend, middlewares: middlewares}
+		backend = used
+	}
+}
+
+// NewMiddlewareBackend returns a backend running the operations of the given
+// backend through the middlewares.
+func NewMiddlewareBackend(b Backend, middlewares ...Middleware) Backend {
+	return &middlewareBackend{backend: b, middlewares: middlewares}
+}
+
+// middlewareBackend implements NewMiddlewareBackend.
+type middlewareBackend struct {
+	backend     Backend
+	middlewares []Middleware
+}
+
+// observe runs fn as the operation with the given name and paths. fn returns
+// the number of bytes transferred, if any.
+func (m *middlewareBackend) observe(name string, paths []string, fn func() (int64, error)) error {
+	op := Operation{Name: name, Paths: paths, Start: time.Now()}
+	ctxs := make([]context.Context, len(m.middlewares))
+	ctx := context.Background()
+	for i, mw := range m.middlewares {
+		if mw.Before != nil {
+			if next := mw.Before(ctx, op); next != nil {
+				ctx = next
+			}
+		}
+		ctxs[i] = ctx
+	}
+	op.Bytes, op.Err = fn()
+	op.Duration = time.Since(op.Start)
+	for i := len(m.middlewares) - 1; i >= 0; i-- {
+		if m.middlewares[i].After != nil {
+			m.middlewares[i].After(ctxs[i], op)
+		}
+	}
+	return op.Err
+}
+
+// run is like observe, for operations that transfer no bytes.
+func (m *middlewareBackend) run(name string, paths []string, fn func() error) error {
+	return m.observe(name, paths, func() (int64, error) {
+		return 0, fn()
+	})
+}
+
+func (m *middlewareBackend) Open(name string) (File, error) {
+	var f File
+	err := m.run(OpOpen, []string{name}, func() (err error) {
+		f, err = m.backend.Open(name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &middlewareFile{File: f, backend: m}, nil
+}
+
+func (m *middlewareBackend) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	var f File
+	err := m.run(OpOpen, []string{name}, func() (err error) {
+		f, err = m.backend.OpenFile(name, flag, perm)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &middlewareFile{File: f, backend: m}, nil
+}
+
+func (m *middlewareBackend) Stat(name string) (info os.FileInfo, err error) {
+	err = m.run(OpStat, []string{name}, func() (err error) {
+		info, err = m.backend.Stat(name)
+		return err
+	})
+	return info, err
+}
+
+func (m *middlewareBackend) Lstat(name string) (info os.FileInfo, err error) {
+	err = m.run(OpStat, []string{name}, func() (err error) {
+		info, err = m.backend.Lstat(name)
+		return err
+	})
+	return info, err
+}
+
+func (m *middlewareBackend) ReadDir(name string) (entries []os.DirEntry, err error) {
+	err = m.run(OpReadDir, []string{name}, func() (err error) {
+		entries, err = m.backend.ReadDir(name)
+		return err
+	})
+	return entries, err
+}
+
+func (m *middlewareBackend) MkdirAll(name string, perm os.FileMode) error {
+	return m.run(OpMkdir, []string{name}, func() error {
+		return m.backend.MkdirAll(name, perm)
+	})
+}
+
+func (m *middlewareBackend) Remove(name string) error {
+	return m.run(OpRemove, []string{name}, func() error {
+		return m.backend.Remove(name)
+	})
+}
+
+func (m *middlewareBackend) RemoveAll(name string) error {
+	return m.run(OpRemove, []string{name}, func() error {
+		return m.backend.RemoveAll(name)
+	})
+}
+
+func (m *middlewareBackend) Rename(oldname, newname string) error {
+	return m.run(OpRename, []string{oldname, newname}, func() error {
+		return m.backend.Rename(oldname, newname)
+	})
+}
+
+func (m *middlewareBackend) Chmod(name string, mode os.FileMode) error {
+	return m.run(OpChmod, []string{name}, func() error {
+		return m.backend.Chmod(name, mode)
+	})
+}
+
+func (m *middlewareBackend) Chown(name string, uid, gid int) error {
+	return m.run(OpChown, []string{name}, func() error {
+		return m.backend.Chown(name, uid, gid)
+	})
+}
+
+func (m *middlewareBackend) Link(oldname, newname string) error {
+	return m.run(OpLink, []string{oldname, newname}, func() error {
+		return m.backend.Link(oldname, newname)
+	})
+}
+
+func (m *middlewareBackend) Symlink(oldname, newname string) error {
+	return m.run(OpSymlink, []string{oldname, newname}, func() error {
+		return m.backend.Symlink(oldname, newname)
+	})
+}
+
+func (m *middlewareBackend) Readlink(name string) (target string, err error) {
+	err = m.run(OpReadlink, []string{name}, func() (err error) {
+		target, err = m.backend.Readlink(name)
+		return err
+	})
+	return target, err
+}
+
+func (m *middlewareBackend) Truncate(name string, size int64) error {
+	return m.run(OpTruncate, []string{name}, func() error {
+		return m.backend.Truncate(name, size)
+	})
+}
+
+// middlewareFile is a file opened by a middlewareBackend, reporting its reads
+// and writes.
+type middlewareFile struct {
+	File
+	backend *middlewareBackend
+}
+
+func (f *middlewareFile) Read(b []byte) (n int, err error) {
+	err = f.backend.observe(OpRead, []string{f.Name()}, func() (int64, error) {
+		n, err = f.File.Read(b)
+		return int64(n), err
+	})
+	return n, err
+}
+
+func (f *middlewareFile) Write(b []byte) (n int, err error) {
+	err = f.backend.observe(OpWrite, []string{f.Name()}, func() (int64, error) {
+		n, err = f.File.Write(b)
+		return int64(n), err
+	})
+	return n, err
+}
@@ -0,0 +1,152 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"expvar"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+type spanKey struct{}
+
+func TestMiddlewareContext(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "file")
+	var spans []string
+	tracer := func(name string) Middleware {
+		return Middleware{
+			Before: func(ctx context.Context, op Operation) context.Context {
+				parent, _ := ctx.Value(spanKey{}).(string)
+				return context.WithValue(ctx, spanKey{}, parent+"/"+name)
+			},
+			After: func(ctx context.Context, op Operation) {
+				if op.Name == OpStat {
+					spans = append(spans, ctx.Value(spanKey{}).(string))
+				}
+			},
+		}
+	}
+	Use(tracer("a"), Middleware{After: func(ctx context.Context, op Operation) {}}, tracer("b"))
+	defer Use()
+
+	Exists(p)
+	want := []string{"/a/b", "/a"}
+	if len(spans) != len(want) || spans[0] != want[0] || spans[1] != want[1] {
+		t.Errorf("spans = %v, want %v", spans, want)
+	}
+}
+
+func TestUseReplacesMiddlewares(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "file")
+	counts := map[string]int{}
+	counter := func(name string) Middleware {
+		return Middleware{After: func(ctx context.Context, op Operation) {
+			counts[name]++
+		}}
+	}
+
+	Use(counter("first"))
+	Use(counter("second"))
+	Exists(p)
+	if counts["first"] != 0 || counts["second"] != 1 {
+		t.Errorf("counts = %v, want only the second middleware to run", counts)
+	}
+
+	Use()
+	Exists(p)
+	if counts["second"] != 1 {
+		t.Errorf("counts = %v, want no middleware to run after Use()", counts)
+	}
+	if _, ok := GetBackend().(osBackend); !ok {
+		t.Errorf("GetBackend() = %T, want the operating system backend", GetBackend())
+	}
+}
+
+func TestSlogMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "file")
+	missing := filepath.Join(dir, "missing")
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+
+	Use(SlogMiddleware(logger, slog.LevelDebug))
+	WriteFileString(p, "data")
+	if buf.Len() != 0 {
+		t.Errorf("logged below the handler level: %s", buf.String())
+	}
+
+	Use(SlogMiddleware(logger, slog.LevelInfo))
+	defer Use()
+	WriteFileString(p, "data")
+	ReadFile(missing)
+
+	type record struct {
+		Msg   string   `json:"msg"`
+		Op    string   `json:"op"`
+		Paths []string `json:"paths"`
+		Bytes *int64   `json:"bytes"`
+		Err   string   `json:"err"`
+	}
+	var records []record
+	for line := range bytes.Lines(buf.Bytes()) {
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, rec)
+	}
+	write := slices.IndexFunc(records, func(rec record) bool { return rec.Op == OpWrite })
+	if write < 0 || records[write].Msg != "fs write" || !slices.Equal(records[write].Paths, []string{p}) ||
+		records[write].Bytes == nil || *records[write].Bytes != 4 {
+		t.Errorf("records = %+v, want a write of 4 bytes to %s", records, p)
+	}
+	last := records[len(records)-1]
+	if last.Op != OpOpen || last.Err == "" || last.Bytes != nil || !slices.Equal(last.Paths, []string{missing}) {
+		t.Errorf("last record = %+v, want a failed open of %s without bytes", last, missing)
+	}
+}
+
+func TestExpvarMiddleware(t *testing.T) {
+	dir := t.TempDir()
+	p := filepath.Join(dir, "file")
+	name := "fs_test_expvar"
+	get := func(op, key string) int64 {
+		m, _ := expvar.Get(name).(*expvar.Map).Get(op).(*expvar.Map)
+		if m == nil {
+			return 0
+		}
+		v, _ := m.Get(key).(*expvar.Int)
+		if v == nil {
+			return 0
+		}
+		return v.Value()
+	}
+
+	Use(ExpvarMiddleware(name))
+	defer Use()
+	WriteFileString(p, "data")
+	ReadFile(filepath.Join(dir, "missing"))
+	if count, bytes := get(OpWrite, "count"), get(OpWrite, "bytes"); count != 1 || bytes != 4 {
+		t.Errorf("write count = %d, bytes = %d, want 1 and 4", count, bytes)
+	}
+	if count, errs := get(OpOpen, "count"), get(OpOpen, "errors"); count != 2 || errs != 1 {
+		t.Errorf("open count = %d, errors = %d, want 2 and 1", count, errs)
+	}
+	var buckets int64
+	expvar.Get(name).(*expvar.Map).Get(OpOpen).(*expvar.Map).Get("latency").(*expvar.Map).Do(func(kv expvar.KeyValue) {
+		buckets += kv.Value.(*expvar.Int).Value()
+	})
+	if buckets != 2 {
+		t.Errorf("open latency histogram holds %d operations, want 2", buckets)
+	}
+
+	// A second middleware with the same name keeps counting in the same map.
+	Use(ExpvarMiddleware(name))
+	WriteFileString(p, "data")
+	if count := get(OpWrite, "count"); count != 2 {
+		t.Errorf("write count = %d after recreating the middleware, want 2", count)
+	}
+}
@@ -0,0 +1,126 @@
+package fs
+
+import (
+	"context"
+	"expvar"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// LatencyBuckets are the upper bounds of the duration histogram buckets used
+// by ExpvarMiddleware. Slower operations fall in the "inf" bucket.
+var LatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	time.Millisecond,
+	10 * time.Millisecond,
+	100 * time.Millisecond,
+	time.Second,
+}
+
+// SlogMiddleware returns a middleware logging each completed operation to the
+// given logger at the given level, with its name, paths, bytes, duration and
+// error. A nil logger uses slog.Default().
+func SlogMiddleware(logger *slog.Logger, level slog.Level) Middleware {
+	return Middleware{
+		After: func(ctx context.Context, op Operation) {
+			l := logger
+			if l == nil {
+				l = slog.Default()
+			}
+			if !l.Enabled(ctx, level) {
+				return
+			}
+			attrs := []slog.Attr{
+				slog.String("op", op.Name),
+				slog.Any("paths", op.Paths),
+				slog.Duration("duration", op.Duration),
+			}
+			if op.Name == OpRead || op.Name == OpWrite {
+				attrs = append(attrs, slog.Int64("bytes", op.Bytes))
+			}
+			if op.Err != nil {
+				attrs = append(attrs, slog.Any("err", op.Err))
+			}
+			l.LogAttrs(ctx, level, "fs "+op.Name, attrs...)
+		},
+	}
+}
+
+// ExpvarMiddleware returns a middleware publishing counters and a duration
+// histogram of the operations as an expvar.Map with the given name. The map
+// holds one entry per operation name with the keys "count", "errors", "bytes",
+// "duration_ns" and "latency", a map from the bucket upper bound in
+// LatencyBuckets (eg: "le_1ms") or "inf" to the number of operations.
+//
+// If a map with the given name is already published, it is reused, so the
+// middleware can be created more than once.
+func ExpvarMiddleware(name string) Middleware {
+	root := publishMap(name)
+	var mu sync.Mutex
+	ops := map[string]*expvar.Map{}
+
+	return Middleware{
+		After: func(ctx context.Context, op Operation) {
+			mu.Lock()
+			m, ok := ops[op.Name]
+			if !ok {
+				if m, ok = root.Get(op.Name).(*expvar.Map); !ok {
+					m = new(expvar.Map)
+					m.Set("latency", new(expvar.Map))
+					root.Set(op.Name, m)
+				}
+				ops[op.Name] = m
+			}
+			mu.Unlock()
+
+			m.Add("count", 1)
+			if op.Err != nil {
+				m.Add("errors", 1)
+			}
+			m.Add("bytes", op.Bytes)
+			m.Add("duration_ns", int64(op.Duration))
+			if latency, ok := m.Get("latency").(*expvar.Map); ok {
+				latency.Add(latencyBucket(op.Duration), 1)
+			}
+		},
+	}
+}
+
+// publishMap returns the expvar.Map published with the given name, publishing
+// a new one if there is none.
+func publishMap(name string) *expvar.Map {
+	publishMu.Lock()
+	defer publishMu.Unlock()
+	if m, ok := expvar.Get(name).(*expvar.Map); ok {
+		return m
+	}
+	return expvar.NewMap(name)
+}
+
+// publishMu serializes publishMap, as expvar panics on duplicate names.
+var publishMu sync.Mutex
+
+// latencyBucket returns the histogram key for the given duration.
+func latencyBucket(d time.Duration) string {
+	for _, bound := range LatencyBuckets {
+		if d <= bound {
+			return "le_" + formatBound(bound)
+		}
+	}
+	return "inf"
+}
+
+// formatBound formats a bucket bound compactly (eg: 100us, 1ms, 1s).
+func formatBound(d time.Duration) string {
+	switch {
+	case d%time.Second == 0:
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	case d%time.Millisecond == 0:
+		return strconv.FormatInt(int64(d/time.Millisecond), 10) + "ms"
+	case d%time.Microsecond == 0:
+		return strconv.FormatInt(int64(d/time.Microsecond), 10) + "us"
+	}
+	return strconv.FormatInt(int64(d), 10) + "ns"
+}
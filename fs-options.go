@@ -0,0 +1,48 @@
+package fs
+
+import "context"
+
+// Option configures how operations behave. Options are accepted by the Context
+// variants of the operations, such as CopyContext, EmptyDirContext and
+// WriteFileContext, so the plain functions keep their signatures.
+type Option func(*options)
+
+type options struct {
+	ctx             context.Context
+	continueOnError bool
+	retry           RetryPolicy
+}
+
+// newOptions applies the given options over the defaults.
+func newOptions(opts []Option) options {
+	o := options{ctx: context.Background(), retry: GetRetryPolicy()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// newContextOptions is like newOptions, for operations running under the given
+// context.
+func newContextOptions(ctx context.Context, opts []Option) options {
+	o := newOptions(opts)
+	o.ctx = ctx
+	return o
+}
+
+// do runs a single step of an operation, retrying it as the retry policy says.
+func (o options) do(fn func() error) error {
+	return o.retry.Do(o.ctx, fn)
+}
+
+// WithContinueOnError makes recursive operations process every entry instead
+// of aborting on the first failure. The failures are returned together as a
+// *MultiError, along with the number of entries processed successfully.
+//
+// The operation still returns its partial result, so Size returns the total
+// size of the files it could read, and Hash the hash of those files.
+func WithContinueOnError() Option {
+	return func(o *options) {
+		o.continueOnError = true
+	}
+}
@@ -0,0 +1,172 @@
+package fs
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Path is a file system path with fluent methods built on the functions of
+// this package. It converts freely from and to strings, so it can be mixed
+// with the string-based functions: Path(s) and p.String().
+//
+// Path implements encoding.TextMarshaler and encoding.TextUnmarshaler, so it
+// is encoded as a plain string in JSON and other text formats.
+type Path string
+
+// NewPath joins any number of path elements into a Path, using the OS-specific
+// path separator. Same as Path(JoinPath(elem...)).
+func NewPath(elem ...string) Path {
+	return Path(JoinPath(elem...))
+}
+
+// String returns the path as a string.
+func (p Path) String() string {
+	return string(p)
+}
+
+// Join joins the path with any number of path elements.
+func (p Path) Join(elem ...string) Path {
+	return Path(JoinPath(append([]string{string(p)}, elem...)...))
+}
+
+// Parent returns the parent directory of the path. Same as GetPathParent.
+func (p Path) Parent() Path {
+	return Path(GetPathParent(string(p)))
+}
+
+// Base returns the last element of the path, including the extension. Same as
+// GetPathBase.
+func (p Path) Base() string {
+	return GetPathBase(string(p))
+}
+
+// Stem returns the last element of the path without the extension. Same as
+// GetPathName.
+func (p Path) Stem() string {
+	return GetPathName(string(p))
+}
+
+// Ext returns the extension of the path, including the dot. Same as
+// GetPathExtension.
+func (p Path) Ext() string {
+	return GetPathExtension(string(p))
+}
+
+// WithExt returns the path with its extension replaced. The extension may be
+// given with or without the dot; an empty extension removes it. Example: for
+// path "/home/user/file.txt", WithExt("md") returns "/home/user/file.md".
+func (p Path) WithExt(ext string) Path {
+	if ext != "" && !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return Path(strings.TrimSuffix(string(p), p.Ext()) + ext)
+}
+
+// WithName returns the path with its last element replaced. Example: for path
+// "/home/user/file.txt", WithName("other.go") returns "/home/user/other.go".
+func (p Path) WithName(name string) Path {
+	return p.Parent().Join(name)
+}
+
+// Parts returns the different components of the path. Same as GetPathParts.
+func (p Path) Parts() PathParts {
+	return GetPathParts(string(p))
+}
+
+// Clean returns the cleaned path. Same as CleanPath.
+func (p Path) Clean() Path {
+	return Path(CleanPath(string(p)))
+}
+
+// IsAbs checks if the path is absolute. Same as IsAbsolutePath.
+func (p Path) IsAbs() bool {
+	return IsAbsolutePath(string(p))
+}
+
+// Abs returns the absolute path. Same as AbsolutePath.
+func (p Path) Abs() (Path, error) {
+	abs, err := AbsolutePath(string(p))
+	return Path(abs), err
+}
+
+// Rel returns the path relative to the given base. Same as
+// RelativePath(base, p).
+func (p Path) Rel(base Path) (Path, error) {
+	rel, err := RelativePath(string(base), string(p))
+	return Path(rel), err
+}
+
+// Exists checks if a file or directory exists at the path.
+func (p Path) Exists() bool {
+	return Exists(string(p))
+}
+
+// IsDir checks if the path is a directory.
+func (p Path) IsDir() bool {
+	return IsDir(string(p))
+}
+
+// IsFile checks if the path is a file.
+func (p Path) IsFile() bool {
+	return IsFile(string(p))
+}
+
+// ReadFile reads the entire content of the file at the path.
+func (p Path) ReadFile() ([]byte, error) {
+	return ReadFile(string(p))
+}
+
+// ReadString reads the entire content of the file at the path as a string.
+func (p Path) ReadString() (string, error) {
+	return ReadFileString(string(p))
+}
+
+// ReadJson reads the JSON file at the path and unmarshals its content into v.
+func (p Path) ReadJson(v any) error {
+	return ReadFileJson(string(p), v)
+}
+
+// WriteFile writes data to the file at the path, overwriting it.
+func (p Path) WriteFile(data []byte) error {
+	return WriteFile(string(p), data)
+}
+
+// WriteString writes a string to the file at the path, overwriting it.
+func (p Path) WriteString(data string) error {
+	return WriteFileString(string(p), data)
+}
+
+// WriteJson marshals v into JSON and writes it to the file at the path,
+// overwriting it.
+func (p Path) WriteJson(v any) error {
+	return WriteFileJson(string(p), v)
+}
+
+// List returns the entries of the directory at the path, joined to it.
+func (p Path) List() ([]Path, error) {
+	names, err := List(string(p))
+	paths := make([]Path, len(names))
+	for i, name := range names {
+		paths[i] = p.Join(name)
+	}
+	return paths, err
+}
+
+// Walk traverses the directory tree rooted at the path, like Walk, calling fn
+// with the path of each entry joined to p.
+func (p Path) Walk(fn func(Path) error) error {
+	return Walk(string(p), func(rel string) error {
+		return fn(Path(filepath.Join(string(p), rel)))
+	})
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (p Path) MarshalText() ([]byte, error) {
+	return []byte(p), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (p *Path) UnmarshalText(data []byte) error {
+	*p = Path(data)
+	return nil
+}
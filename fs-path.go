@@ -1,6 +1,10 @@
 package fs
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
 	"path/filepath"
 	"strings"
 )
@@ -41,35 +45,224 @@ func JoinPathWith(sep string, elem ...string) string {
 	return strings.Join(elem, sep)
 }
 
-// AbsolutePath returns the absolute path for the given path.
-func AbsolutePath(p string) (string, error) {
+// PathOption configures how AbsolutePath and ExpandPath handle a path.
+type PathOption func(*pathOptions)
+
+type pathOptions struct {
+	expand bool
+	strict bool
+}
+
+// WithExpand makes AbsolutePath expand the home directory and environment
+// variables before resolving the path. See ExpandPath.
+func WithExpand() PathOption {
+	return func(o *pathOptions) {
+		o.expand = true
+	}
+}
+
+// WithStrictExpand is like WithExpand, but referencing an undefined environment
+// variable without a default value is an error.
+func WithStrictExpand() PathOption {
+	return func(o *pathOptions) {
+		o.expand = true
+		o.strict = true
+	}
+}
+
+// AbsolutePath returns the absolute path for the given path. With the
+// WithExpand option, it expands the path with ExpandPath first.
+func AbsolutePath(p string, opts ...PathOption) (string, error) {
+	options := pathOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.expand {
+		expanded, err := expandPath(p, options.strict)
+		if err != nil {
+			return "", newError("AbsolutePath", p, err)
+		}
+		p = expanded
+	}
+
 	absPath, err := filepath.Abs(p)
 	if err != nil {
-		return "", err
+		return "", newError("AbsolutePath", p, err)
 	}
 	return absPath, nil
 }
 
 // ForceAbsolutePath is like AbsolutePath but ignores any errors and returns
 // the absolute path.
-func ForceAbsolutePath(p string) string {
-	abs, _ := AbsolutePath(p)
+func ForceAbsolutePath(p string, opts ...PathOption) string {
+	abs, _ := AbsolutePath(p, opts...)
 	return abs
 }
 
+// ExpandPath expands the home directory and environment variables in the
+// given path, as a shell would:
+//
+//   - A leading "~" is replaced by the home directory of the current user, and
+//     a leading "~name" by the home directory of the user "name".
+//   - "$VAR" and "${VAR}" are replaced by the value of the environment
+//     variable, or an empty string if it is not defined.
+//   - "${VAR:-default}" is replaced by the value of the environment variable,
+//     or by "default" if it is not defined or empty. The default is expanded
+//     as well, so it may reference other variables (eg: "${A:-${B}}").
+//
+// A "~name" for an unknown user is left unexpanded. With the WithStrictExpand
+// option, it returns an error instead, and so does referencing an undefined
+// variable without a default value, wrapping ErrUndefinedVariable.
+func ExpandPath(p string, opts ...PathOption) (string, error) {
+	options := pathOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	expanded, err := expandPath(p, options.strict)
+	if err != nil {
+		return "", newError("ExpandPath", p, err)
+	}
+	return expanded, nil
+}
+
+// ForceExpandPath is like ExpandPath but ignores any errors and returns the
+// path unchanged in such cases.
+func ForceExpandPath(p string, opts ...PathOption) string {
+	expanded, err := ExpandPath(p, opts...)
+	if err != nil {
+		return p
+	}
+	return expanded
+}
+
+// expandPath implements ExpandPath.
+func expandPath(p string, strict bool) (string, error) {
+	p, err := expandHome(p, strict)
+	if err != nil {
+		return "", err
+	}
+	return expandVariables(p, strict)
+}
+
+// expandHome replaces a leading "~" or "~name" by the corresponding home
+// directory. Unless strict, a "~name" for an unknown user is left unchanged.
+func expandHome(p string, strict bool) (string, error) {
+	if !strings.HasPrefix(p, "~") {
+		return p, nil
+	}
+
+	name, rest := p[1:], ""
+	if i := strings.IndexAny(name, "/"+PathSeparator); i >= 0 {
+		name, rest = name[:i], name[i:]
+	}
+
+	var home string
+	if name == "" {
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		home = dir
+	} else {
+		u, err := user.Lookup(name)
+		var unknown user.UnknownUserError
+		if errors.As(err, &unknown) && !strict {
+			return p, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		home = u.HomeDir
+	}
+	return home + rest, nil
+}
+
+// expandVariables replaces the "$VAR", "${VAR}" and "${VAR:-default}"
+// references by their values.
+func expandVariables(p string, strict bool) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		if p[i] != '$' || i+1 == len(p) {
+			b.WriteByte(p[i])
+			continue
+		}
+
+		var name, fallback string
+		var hasFallback bool
+		if p[i+1] == '{' {
+			end := matchingBrace(p[i+2:])
+			if end < 0 {
+				return "", ErrInvalid
+			}
+			name = p[i+2 : i+2+end]
+			name, fallback, hasFallback = strings.Cut(name, ":-")
+			i += end + 2
+		} else {
+			end := i + 1
+			for end < len(p) && isVariableChar(p[end], end == i+1) {
+				end++
+			}
+			if end == i+1 {
+				b.WriteByte('$')
+				continue
+			}
+			name = p[i+1 : end]
+			i = end - 1
+		}
+
+		value, ok := os.LookupEnv(name)
+		switch {
+		case hasFallback && value == "":
+			expanded, err := expandVariables(fallback, strict)
+			if err != nil {
+				return "", err
+			}
+			value = expanded
+		case !ok && strict:
+			return "", fmt.Errorf("%w: %s", ErrUndefinedVariable, name)
+		}
+		b.WriteString(value)
+	}
+	return b.String(), nil
+}
+
+// matchingBrace returns the index of the "}" closing a "${" reference whose
+// content starts s, skipping nested references, or -1 if there is none.
+func matchingBrace(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+			depth++
+			i++
+		case s[i] == '}' && depth == 0:
+			return i
+		case s[i] == '}':
+			depth--
+		}
+	}
+	return -1
+}
+
+// isVariableChar reports whether c can be part of an environment variable
+// name. Names cannot start with a digit.
+func isVariableChar(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+}
+
 // CleanAbsolutePath returns the cleaned absolute path for the given path.
 func RelativePath(base, target string) (string, error) {
 	absBase, err := AbsolutePath(base)
 	if err != nil {
-		return target, err
+		return target, newError("RelativePath", base, err)
 	}
 	absTarget, err := AbsolutePath(target)
 	if err != nil {
-		return target, err
+		return target, newError("RelativePath", target, err)
 	}
 	relPath, err := filepath.Rel(absBase, absTarget)
 	if err != nil {
-		return target, err
+		return target, newError("RelativePath", target, err)
 	}
 	return relPath, nil
 }
@@ -0,0 +1,50 @@
+package fs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExpandPath(t *testing.T) {
+	t.Setenv("FS_TEST_A", "a")
+	t.Setenv("FS_TEST_EMPTY", "")
+
+	tests := []struct {
+		p      string
+		strict bool
+		want   string
+		err    error
+	}{
+		{"$FS_TEST_A/x", false, "a/x", nil},
+		{"${FS_TEST_A}x", false, "ax", nil},
+		{"${FS_TEST_EMPTY:-d}", false, "d", nil},
+		{"${FS_TEST_UNSET:-${FS_TEST_A}}/x", false, "a/x", nil},
+		{"${FS_TEST_UNSET:-${FS_TEST_UNSET2:-${FS_TEST_A}}}", false, "a", nil},
+		{"${FS_TEST_A:-${FS_TEST_UNSET}}", true, "a", nil},
+		{"${FS_TEST_UNSET:-${FS_TEST_UNSET2}}", true, "", ErrUndefinedVariable},
+		{"${FS_TEST_UNSET}", false, "", nil},
+		{"${FS_TEST_UNSET}", true, "", ErrUndefinedVariable},
+		{"${FS_TEST_A", false, "", ErrInvalid},
+		{"~fs-test-no-such-user/x", false, "~fs-test-no-such-user/x", nil},
+	}
+	for _, tt := range tests {
+		var opts []PathOption
+		if tt.strict {
+			opts = append(opts, WithStrictExpand())
+		}
+		got, err := ExpandPath(tt.p, opts...)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("ExpandPath(%q) = %q, %v, want error %v", tt.p, got, err, tt.err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ExpandPath(%q) = %q, %v, want %q", tt.p, got, err, tt.want)
+		}
+	}
+
+	if _, err := ExpandPath("~fs-test-no-such-user/x", WithStrictExpand()); err == nil {
+		t.Errorf("ExpandPath with an unknown user in strict mode succeeded")
+	}
+}
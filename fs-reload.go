@@ -0,0 +1,187 @@
+package fs
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultReloadDebounce is the debounce duration used by new Reloadable
+// values. Bursts of events within this duration trigger a single reload.
+const DefaultReloadDebounce = 100 * time.Millisecond
+
+// Reloadable holds a value decoded from a file and keeps it up to date while
+// the file changes. It is safe for concurrent use.
+//
+// When the file changes, it is reloaded after a debounce period. If reading or
+// decoding fails, the last good value is kept and the error is reported to the
+// error subscribers.
+type Reloadable[T any] struct {
+	path      string
+	unmarshal func(data []byte, v any) error
+
+	mu       sync.RWMutex
+	value    T
+	err      error
+	debounce time.Duration
+	onChange []func(value T)
+	onError  []func(err error)
+
+	reloading sync.Mutex
+}
+
+// NewReloadable loads the JSON file at the specified path into a value of type
+// T and reloads it whenever the file changes, until the context is done. If the
+// initial load fails, it returns an error.
+func NewReloadable[T any](ctx context.Context, p string) (*Reloadable[T], error) {
+	return NewReloadableFunc[T](ctx, p, json.Unmarshal)
+}
+
+// NewReloadableFunc is like NewReloadable but decodes the file with the
+// provided unmarshal function, such as yaml.Unmarshal or toml.Unmarshal, so
+// any format with a json.Unmarshal-like function can be used.
+func NewReloadableFunc[T any](ctx context.Context, p string, unmarshal func(data []byte, v any) error) (*Reloadable[T], error) {
+	r := &Reloadable[T]{
+		path:      p,
+		unmarshal: unmarshal,
+		debounce:  DefaultReloadDebounce,
+	}
+
+	// The watch is registered before the initial load, so changes made in
+	// between are not missed.
+	w, err := NewWatcher()
+	if err != nil {
+		return nil, newError("NewReloadable", p, err)
+	}
+	err = w.Add(filepath.Dir(filepath.Clean(p)))
+	if err != nil {
+		w.Close()
+		return nil, newError("NewReloadable", p, err)
+	}
+
+	err = r.Reload()
+	if err != nil {
+		w.Close()
+		return nil, newError("NewReloadable", p, err)
+	}
+
+	go r.watch(ctx, w)
+	return r, nil
+}
+
+// watch reloads the value on every change of the file, debouncing bursts of
+// events, until the context is done.
+func (r *Reloadable[T]) watch(ctx context.Context, w *Watcher) {
+	defer w.Close()
+
+	var timer *time.Timer
+	w.watch(ctx, fileFilter(r.path), func(event Event) {
+		if event.Has(EvtError) {
+			r.fail(event.Err)
+			return
+		}
+		if !event.Has(EvtWrite) {
+			return
+		}
+
+		r.mu.RLock()
+		debounce := r.debounce
+		r.mu.RUnlock()
+
+		if timer == nil {
+			timer = time.AfterFunc(debounce, func() { r.Reload() })
+		} else {
+			timer.Reset(debounce)
+		}
+	})
+	if timer != nil {
+		timer.Stop()
+	}
+}
+
+// Get returns the current value.
+func (r *Reloadable[T]) Get() T {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.value
+}
+
+// Err returns the error of the last reload, or nil if it succeeded.
+func (r *Reloadable[T]) Err() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.err
+}
+
+// Path returns the path of the file being watched.
+func (r *Reloadable[T]) Path() string {
+	return r.path
+}
+
+// SetDebounce sets the duration to wait for further changes before reloading
+// the file.
+func (r *Reloadable[T]) SetDebounce(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.debounce = d
+}
+
+// OnChange registers a function to be called with the new value after every
+// successful reload.
+func (r *Reloadable[T]) OnChange(fn func(value T)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onChange = append(r.onChange, fn)
+}
+
+// OnError registers a function to be called when a reload fails. The previous
+// value is kept in such cases.
+func (r *Reloadable[T]) OnError(fn func(err error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onError = append(r.onError, fn)
+}
+
+// Reload reads and decodes the file immediately. On success the value is
+// replaced and the change subscribers are notified; on failure the last good
+// value is kept, the error subscribers are notified and the error returned.
+func (r *Reloadable[T]) Reload() error {
+	r.reloading.Lock()
+	defer r.reloading.Unlock()
+
+	var value T
+	data, err := ReadFile(r.path)
+	if err == nil {
+		err = r.unmarshal(data, &value)
+	}
+	if err != nil {
+		err = newError("Reload", r.path, err)
+		r.fail(err)
+		return err
+	}
+
+	r.mu.Lock()
+	r.value = value
+	r.err = nil
+	subscribers := r.onChange
+	r.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(value)
+	}
+	return nil
+}
+
+// fail records the error and notifies the error subscribers.
+func (r *Reloadable[T]) fail(err error) {
+	r.mu.Lock()
+	r.err = err
+	subscribers := r.onError
+	r.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(err)
+	}
+}
@@ -0,0 +1,116 @@
+package fs
+
+import (
+	"context"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// RetryPolicy describes how operations are retried when they fail with an
+// error that is likely to go away, such as a file busy on a network share or
+// locked by an antivirus scanner.
+//
+// Retries apply to operations that can be safely repeated: WriteFile, Copy,
+// Move, Rename, Remove and EmptyDir. Operations appending to files are never
+// retried. To use a policy for a single call, pass WithRetry to the Context
+// variant of the operation, such as RemoveContext.
+type RetryPolicy struct {
+	Attempts   int              // Total number of attempts, including the first one. 0 or 1 disables retries
+	Delay      time.Duration    // Wait before the first retry
+	MaxDelay   time.Duration    // Maximum wait between retries, 0 for no limit
+	Multiplier float64          // Growth of the wait after each retry, 2 if 0
+	Jitter     float64          // Random fraction of the wait added or removed, between 0 and 1
+	Retryable  func(error) bool // Errors worth retrying, IsTransient if nil
+}
+
+// DefaultRetryPolicy is a reasonable policy for flaky volumes, giving up after
+// less than a second of retries. Enable it with SetRetryPolicy or WithRetry.
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:   5,
+	Delay:      50 * time.Millisecond,
+	MaxDelay:   time.Second,
+	Multiplier: 2,
+	Jitter:     0.2,
+}
+
+var (
+	retryMu     sync.RWMutex
+	retryPolicy RetryPolicy
+)
+
+// SetRetryPolicy sets the retry policy used by every operation that does not
+// receive one with WithRetry. By default, operations are not retried; use the
+// zero RetryPolicy to disable retries again.
+func SetRetryPolicy(policy RetryPolicy) {
+	retryMu.Lock()
+	defer retryMu.Unlock()
+	retryPolicy = policy
+}
+
+// GetRetryPolicy returns the retry policy set with SetRetryPolicy.
+func GetRetryPolicy() RetryPolicy {
+	retryMu.RLock()
+	defer retryMu.RUnlock()
+	return retryPolicy
+}
+
+// WithRetry makes the operation use the given retry policy instead of the one
+// set with SetRetryPolicy.
+func WithRetry(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retry = policy
+	}
+}
+
+// Do calls fn until it succeeds, it fails with an error that is not
+// retryable, or the attempts run out, waiting between attempts as the policy
+// describes, and returns the error of the last attempt. If the context is done
+// before the first attempt or while waiting for a retry, it returns the error
+// of the context instead.
+func (p RetryPolicy) Do(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	err := fn()
+	for attempt := 1; attempt < p.Attempts && err != nil && p.isRetryable(err); attempt++ {
+		timer := time.NewTimer(p.Backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+		err = fn()
+	}
+	return err
+}
+
+// Backoff returns how long to wait before the given retry, starting at 1.
+func (p RetryPolicy) Backoff(retry int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = 2
+	}
+	delay := float64(p.Delay)
+	for i := 1; i < retry; i++ {
+		delay *= multiplier
+		if p.MaxDelay > 0 && delay >= float64(p.MaxDelay) {
+			break
+		}
+	}
+	if p.MaxDelay > 0 && delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay)
+}
+
+func (p RetryPolicy) isRetryable(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsTransient(err)
+}
@@ -0,0 +1,139 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// busyPolicy retries EBUSY quickly, on every platform.
+var busyPolicy = RetryPolicy{
+	Attempts:  3,
+	Delay:     time.Millisecond,
+	Retryable: func(err error) bool { return errors.Is(err, syscall.EBUSY) },
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		failures int   // Attempts failing with EBUSY before succeeding
+		fail     error // Error of every attempt instead, if not nil
+		want     error
+		calls    int
+	}{
+		{"success", context.Background(), 0, nil, nil, 1},
+		{"retried", context.Background(), 2, nil, nil, 3},
+		{"attempts run out", context.Background(), 3, nil, syscall.EBUSY, 3},
+		{"not retryable", context.Background(), 0, ErrPermission, ErrPermission, 1},
+		{"cancelled", canceled, 0, nil, context.Canceled, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			err := busyPolicy.Do(tt.ctx, func() error {
+				calls++
+				if tt.fail != nil {
+					return tt.fail
+				}
+				if calls <= tt.failures {
+					return syscall.EBUSY
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.want) || (tt.want == nil && err != nil) {
+				t.Errorf("Do() error = %v, want %v", err, tt.want)
+			}
+			if calls != tt.calls {
+				t.Errorf("Do() called fn %d times, want %d", calls, tt.calls)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDoCancelledWhileWaiting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	policy := RetryPolicy{Attempts: 3, Delay: time.Hour, Retryable: busyPolicy.Retryable}
+	calls := 0
+	err := policy.Do(ctx, func() error {
+		calls++
+		cancel()
+		return syscall.EBUSY
+	})
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("Do() = %v after %d calls, want context.Canceled after 1", err, calls)
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	tests := []struct {
+		policy RetryPolicy
+		want   []time.Duration
+	}{
+		{RetryPolicy{Delay: 10 * time.Millisecond}, []time.Duration{10, 20, 40, 80}},
+		{RetryPolicy{Delay: 10 * time.Millisecond, Multiplier: 3}, []time.Duration{10, 30, 90, 270}},
+		{RetryPolicy{Delay: 10 * time.Millisecond, MaxDelay: 50 * time.Millisecond}, []time.Duration{10, 20, 40, 50}},
+	}
+	for _, tt := range tests {
+		for i, want := range tt.want {
+			if got := tt.policy.Backoff(i + 1); got != want*time.Millisecond {
+				t.Errorf("%+v.Backoff(%d) = %v, want %v", tt.policy, i+1, got, want*time.Millisecond)
+			}
+		}
+	}
+
+	jittered := RetryPolicy{Delay: 100 * time.Millisecond, Jitter: 0.2}
+	for range 20 {
+		if got := jittered.Backoff(1); got < 80*time.Millisecond || got > 120*time.Millisecond {
+			t.Errorf("Backoff() with jitter = %v, want within 20%% of 100ms", got)
+		}
+	}
+}
+
+func TestRetryOptions(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a": "a", "b": "b"})
+	faults := withFaults(t)
+	join := func(name string) string { return filepath.Join(dir, name) }
+
+	// Without a policy, the first failure is returned.
+	faults.Inject(Fault{Op: OpRename, Err: syscall.EBUSY, Times: 1})
+	if err := Rename(join("a"), join("c")); !errors.Is(err, syscall.EBUSY) {
+		t.Errorf("Rename() without retries error = %v, want EBUSY", err)
+	}
+
+	faults.Inject(Fault{Op: OpRename, Err: syscall.EBUSY, Times: 2})
+	if err := RenameContext(context.Background(), join("a"), join("c"), WithRetry(busyPolicy)); err != nil {
+		t.Errorf("RenameContext() with WithRetry error = %v", err)
+	}
+
+	SetRetryPolicy(busyPolicy)
+	defer SetRetryPolicy(RetryPolicy{})
+	faults.Inject(Fault{Op: OpRename, Err: syscall.EBUSY, Times: 2})
+	if err := Move(join("c"), join("d")); err != nil {
+		t.Errorf("Move() with SetRetryPolicy error = %v", err)
+	}
+	faults.Inject(Fault{Op: OpRename, Err: syscall.EBUSY, Times: 3})
+	if err := Move(join("d"), join("e")); !errors.Is(err, syscall.EBUSY) {
+		t.Errorf("Move() failing every attempt error = %v, want EBUSY", err)
+	}
+	faults.Reset()
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := WriteFileContext(canceled, join("b"), []byte("new")); !errors.Is(err, context.Canceled) {
+		t.Errorf("WriteFileContext() error = %v, want context.Canceled", err)
+	}
+	if err := MoveContext(canceled, join("d"), join("f")); !errors.Is(err, context.Canceled) {
+		t.Errorf("MoveContext() error = %v, want context.Canceled", err)
+	}
+	SetBackend(nil)
+	checkContent(t, join("b"), "b")
+	checkContent(t, join("d"), "a")
+}
@@ -0,0 +1,333 @@
+package fs
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Root is a handle confined to a directory. Every operation takes paths
+// relative to the root and is guaranteed to stay inside it: absolute paths and
+// paths escaping the root with ".." components or symbolic links pointing
+// outside of it fail, and since it is built on os.Root, the checks are not subject to races with
+// concurrent renames.
+//
+// Root is useful to hand a component a directory it cannot escape, for example
+// when dealing with user-provided names.
+type Root struct {
+	root *os.Root
+}
+
+// OpenRoot opens the directory at the specified path as a Root. The returned
+// Root must be closed when no longer needed.
+func OpenRoot(dir string) (*Root, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, newError("OpenRoot", dir, err)
+	}
+	return &Root{root: root}, nil
+}
+
+// Name returns the name of the directory, as given to OpenRoot.
+func (r *Root) Name() string {
+	return r.root.Name()
+}
+
+// Close closes the root. Files opened from it remain usable.
+func (r *Root) Close() error {
+	return r.root.Close()
+}
+
+// OpenRoot opens a subdirectory of the root as a new Root, confined to it.
+func (r *Root) OpenRoot(p string) (*Root, error) {
+	root, err := r.root.OpenRoot(p)
+	if err != nil {
+		return nil, newError("OpenRoot", p, err)
+	}
+	return &Root{root: root}, nil
+}
+
+// FS returns an io/fs.FS for the root, for use with the standard library.
+func (r *Root) FS() iofs.FS {
+	return r.root.FS()
+}
+
+// Open opens the file at the specified path for reading.
+func (r *Root) Open(p string) (*os.File, error) {
+	f, err := r.root.Open(p)
+	if err != nil {
+		return nil, newError("Open", p, err)
+	}
+	return f, nil
+}
+
+// OpenFile opens the file at the specified path with the given flags and
+// permissions, like os.OpenFile.
+func (r *Root) OpenFile(p string, flag int, perm os.FileMode) (*os.File, error) {
+	f, err := r.root.OpenFile(p, flag, perm)
+	if err != nil {
+		return nil, newError("OpenFile", p, err)
+	}
+	return f, nil
+}
+
+// Exists checks if a file or directory exists at the given path. Paths that
+// are absolute or escape the root do not exist.
+func (r *Root) Exists(p string) bool {
+	_, err := r.root.Stat(p)
+	return err == nil || errors.Is(err, os.ErrPermission)
+}
+
+// IsFile checks if the given path is a file. If the path does not exist or is
+// a directory, it returns false.
+func (r *Root) IsFile(p string) bool {
+	info, err := r.root.Stat(p)
+	return err == nil && !info.IsDir()
+}
+
+// IsDir checks if the given path is a directory. If the path does not exist or
+// is a file, it returns false.
+func (r *Root) IsDir(p string) bool {
+	info, err := r.root.Stat(p)
+	return err == nil && info.IsDir()
+}
+
+// GetInfo returns a FileInfo describing the file at the specified path.
+func (r *Root) GetInfo(p string) (os.FileInfo, error) {
+	info, err := r.root.Stat(p)
+	if err != nil {
+		return nil, newError("GetInfo", p, err)
+	}
+	return info, nil
+}
+
+// ReadFile reads the entire content of a file and returns it as a byte slice.
+func (r *Root) ReadFile(p string) ([]byte, error) {
+	data, err := r.root.ReadFile(p)
+	return data, newError("ReadFile", p, err)
+}
+
+// ReadFileString reads the entire content of a file and returns it as a string.
+func (r *Root) ReadFileString(p string) (string, error) {
+	data, err := r.ReadFile(p)
+	return string(data), newError("ReadFileString", p, err)
+}
+
+// ReadFileLines reads a file and returns its content as a slice of strings,
+// where each string represents a line in the file.
+func (r *Root) ReadFileLines(p string) ([]string, error) {
+	data, err := r.ReadFile(p)
+	if err != nil {
+		return []string{}, newError("ReadFileLines", p, err)
+	}
+	return strings.Split(string(data), "\n"), nil
+}
+
+// ReadFileJson reads a JSON file and unmarshals its content into the provided
+// variable v, which should be a pointer to the desired data structure.
+func (r *Root) ReadFileJson(p string, v any) error {
+	data, err := r.ReadFile(p)
+	if err == nil {
+		err = json.Unmarshal(data, v)
+	}
+	return newError("ReadFileJson", p, err)
+}
+
+// WriteFile writes the given byte slice data to a file at the specified path.
+// If the directory does not exist, it will fail. If the file exists, it will
+// be overwritten.
+func (r *Root) WriteFile(p string, data []byte) error {
+	return newError("WriteFile", p, r.root.WriteFile(p, data, 0644))
+}
+
+// WriteFileString writes the given string data to a file at the specified path.
+func (r *Root) WriteFileString(p string, data string) error {
+	return newError("WriteFileString", p, r.WriteFile(p, []byte(data)))
+}
+
+// WriteFileLines writes the given slice of strings to a file at the specified
+// path, with each string representing a line in the file.
+func (r *Root) WriteFileLines(p string, lines []string) error {
+	return newError("WriteFileLines", p, r.WriteFile(p, []byte(strings.Join(lines, "\n"))))
+}
+
+// WriteFileJson marshals the given variable v into JSON format and writes it to
+// a file at the specified path.
+func (r *Root) WriteFileJson(p string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err == nil {
+		err = r.WriteFile(p, data)
+	}
+	return newError("WriteFileJson", p, err)
+}
+
+// AppendFile appends the given byte slice data to a file at the specified path.
+// If the file does not exist, it will be created.
+func (r *Root) AppendFile(p string, data []byte) error {
+	f, err := r.root.OpenFile(p, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return newError("AppendFile", p, err)
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	return newError("AppendFile", p, err)
+}
+
+// List returns a slice of names of all entries (files and directories) within
+// the specified directory. This function is not recursive.
+func (r *Root) List(p string) ([]string, error) {
+	base, err := r.fsPath(p)
+	if err != nil {
+		return nil, newError("List", p, err)
+	}
+	entries, err := iofs.ReadDir(r.root.FS(), base)
+	names := make([]string, len(entries))
+	for i, entry := range entries {
+		names[i] = entry.Name()
+	}
+	return names, newError("List", p, err)
+}
+
+// ListRecursive returns a slice of relative paths of all entries (files and
+// directories) within the specified directory and its subdirectories. The
+// returned paths are relative to the specified directory.
+func (r *Root) ListRecursive(p string) ([]string, error) {
+	base, err := r.fsPath(p)
+	if err != nil {
+		return nil, newError("ListRecursive", p, err)
+	}
+	results := []string{}
+	err = iofs.WalkDir(r.root.FS(), base, func(name string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == base {
+			if !d.IsDir() {
+				return ErrNotDir
+			}
+			return nil
+		}
+		rel := name
+		if base != "." {
+			rel = strings.TrimPrefix(name, base+"/")
+		}
+		results = append(results, filepath.FromSlash(rel))
+		return nil
+	})
+	if err != nil {
+		return nil, newError("ListRecursive", p, err)
+	}
+	return results, nil
+}
+
+// CreateDir creates a directory at the specified path, including any necessary
+// parent directories. If the directory already exists, it does nothing.
+func (r *Root) CreateDir(p string) error {
+	return newError("CreateDir", p, r.root.MkdirAll(p, 0755))
+}
+
+// Copy copies a file or directory from src to dst, both inside the root. If
+// src is a directory, it copies the entire directory recursively, merging it
+// with dst if it exists. If src is a file, dst is overwritten.
+func (r *Root) Copy(src, dst string) error {
+	base, err := r.fsPath(src)
+	if err != nil {
+		return newLinkError("Copy", src, dst, err)
+	}
+	err = iofs.WalkDir(r.root.FS(), base, func(name string, d iofs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		target := dst
+		if name != base && base == "." {
+			target = path.Join(ToSlashPath(dst), name)
+		} else if name != base {
+			target = path.Join(ToSlashPath(dst), strings.TrimPrefix(name, base+"/"))
+		}
+		if d.IsDir() {
+			return r.root.MkdirAll(target, 0755)
+		}
+		return r.copyFile(name, target)
+	})
+	return newLinkError("Copy", src, dst, err)
+}
+
+// copyFile copies a single file inside the root.
+func (r *Root) copyFile(src, dst string) error {
+	srcFile, err := r.root.Open(src)
+	if err != nil {
+		return err
+	}
+	defer srcFile.Close()
+
+	dstFile, err := r.root.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer dstFile.Close()
+
+	_, err = io.Copy(dstFile, srcFile)
+	return err
+}
+
+// Move moves a file or directory from src to dst, both inside the root.
+func (r *Root) Move(src, dst string) error {
+	return newLinkError("Move", src, dst, r.root.Rename(src, dst))
+}
+
+// Remove removes a file or directory at the specified path. If the path is a
+// directory, it removes the directory and all its contents recursively. If the
+// path does not exist, it returns nil.
+func (r *Root) Remove(p string) error {
+	return newError("Remove", p, r.root.RemoveAll(p))
+}
+
+// EmptyDir removes all contents of the directory at the specified path without
+// deleting the directory itself.
+func (r *Root) EmptyDir(p string) error {
+	if !r.IsDir(p) {
+		err := ErrNotDir
+		if _, statErr := r.root.Stat(p); statErr != nil {
+			err = statErr
+		}
+		return newError("EmptyDir", p, err)
+	}
+	entries, err := r.List(p)
+	if err != nil {
+		return newError("EmptyDir", p, err)
+	}
+	for _, entry := range entries {
+		err := r.root.RemoveAll(filepath.Join(p, entry))
+		if err != nil {
+			return newError("EmptyDir", filepath.Join(p, entry), err)
+		}
+	}
+	return nil
+}
+
+// Chmod changes the mode of the file at the specified path.
+func (r *Root) Chmod(p string, mode os.FileMode) error {
+	return newError("Chmod", p, r.root.Chmod(p, mode))
+}
+
+// Symlink creates a symbolic link at newname pointing to oldname. The link may
+// point anywhere, but following it through the root never escapes it.
+func (r *Root) Symlink(oldname, newname string) error {
+	return newLinkError("Symlink", oldname, newname, r.root.Symlink(oldname, newname))
+}
+
+// fsPath converts a path relative to the root into the form expected by io/fs
+// functions. The path is checked by the root first, so absolute and escaping
+// paths fail with the same error as in the other methods, instead of being
+// interpreted by io/fs.
+func (r *Root) fsPath(p string) (string, error) {
+	if _, err := r.root.Stat(p); err != nil {
+		return "", err
+	}
+	return path.Clean(ToSlashPath(p)), nil
+}
@@ -0,0 +1,112 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRootConfinement(t *testing.T) {
+	dir := t.TempDir()
+	outside := filepath.Join(dir, "outside")
+	writeTree(t, outside, map[string]string{"secret": "secret", "sub/x": "x"})
+	rootDir := filepath.Join(dir, "root")
+	writeTree(t, rootDir, map[string]string{"inside": "inside"})
+	if err := os.Symlink(outside, filepath.Join(rootDir, "link")); err != nil {
+		t.Skip("symbolic links not supported:", err)
+	}
+	before, err := ListRecursive(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root, err := OpenRoot(rootDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer root.Close()
+
+	ops := []struct {
+		name string
+		fn   func(p string) error
+	}{
+		{"OpenRoot", func(p string) error {
+			r, err := root.OpenRoot(p)
+			if err == nil {
+				r.Close()
+			}
+			return err
+		}},
+		{"Open", func(p string) error {
+			f, err := root.Open(p)
+			if err == nil {
+				f.Close()
+			}
+			return err
+		}},
+		{"OpenFile", func(p string) error {
+			f, err := root.OpenFile(p, os.O_RDWR|os.O_CREATE, 0644)
+			if err == nil {
+				f.Close()
+			}
+			return err
+		}},
+		{"GetInfo", func(p string) error { _, err := root.GetInfo(p); return err }},
+		{"ReadFile", func(p string) error { _, err := root.ReadFile(p); return err }},
+		{"ReadFileString", func(p string) error { _, err := root.ReadFileString(p); return err }},
+		{"ReadFileLines", func(p string) error { _, err := root.ReadFileLines(p); return err }},
+		{"ReadFileJson", func(p string) error { var v any; return root.ReadFileJson(p, &v) }},
+		{"WriteFile", func(p string) error { return root.WriteFile(p, []byte("x")) }},
+		{"WriteFileString", func(p string) error { return root.WriteFileString(p, "x") }},
+		{"WriteFileLines", func(p string) error { return root.WriteFileLines(p, []string{"x"}) }},
+		{"WriteFileJson", func(p string) error { return root.WriteFileJson(p, "x") }},
+		{"AppendFile", func(p string) error { return root.AppendFile(p, []byte("x")) }},
+		{"List", func(p string) error { _, err := root.List(p); return err }},
+		{"ListRecursive", func(p string) error { _, err := root.ListRecursive(p); return err }},
+		{"CreateDir", func(p string) error { return root.CreateDir(filepath.Join(p, "new")) }},
+		{"Copy/src", func(p string) error { return root.Copy(p, "copy") }},
+		{"Copy/dst", func(p string) error { return root.Copy("inside", filepath.Join(p, "copy")) }},
+		{"Move/src", func(p string) error { return root.Move(p, "moved") }},
+		{"Move/dst", func(p string) error { return root.Move("inside", filepath.Join(p, "moved")) }},
+		{"Remove", func(p string) error { return root.Remove(p) }},
+		{"EmptyDir", func(p string) error { return root.EmptyDir(p) }},
+		{"Chmod", func(p string) error { return root.Chmod(p, 0600) }},
+		{"Symlink", func(p string) error { return root.Symlink("inside", filepath.Join(p, "symlink")) }},
+	}
+	paths := []string{
+		filepath.Join("..", "outside"),
+		filepath.Join("..", "outside", "secret"),
+		outside,
+		filepath.Join(outside, "secret"),
+		string(filepath.Separator),
+		string(filepath.Separator) + "inside",
+		filepath.Join("link", "sub"),
+		filepath.Join("link", "secret"),
+	}
+
+	for _, p := range paths {
+		if root.Exists(p) || root.IsFile(p) || root.IsDir(p) {
+			t.Errorf("Exists/IsFile/IsDir(%q) = true, want false", p)
+		}
+		for _, op := range ops {
+			if err := op.fn(p); err == nil {
+				t.Errorf("%s(%q) = nil, want an error", op.name, p)
+			}
+		}
+	}
+
+	after, err := ListRecursive(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(before, after) {
+		t.Errorf("outside entries = %v, want %v", after, before)
+	}
+	checkContent(t, filepath.Join(outside, "secret"), "secret")
+	checkContent(t, filepath.Join(outside, "sub", "x"), "x")
+	checkContent(t, filepath.Join(rootDir, "inside"), "inside")
+	if info, err := os.Stat(filepath.Join(outside, "secret")); err != nil || info.Mode().Perm() == 0600 {
+		t.Errorf("outside secret mode changed: %v, %v", info, err)
+	}
+}
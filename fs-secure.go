@@ -0,0 +1,145 @@
+package fs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// maxSymlinks is the maximum number of symbolic links SecureJoin follows
+// before giving up, matching the limit of most systems.
+const maxSymlinks = 255
+
+// EscapeError is returned by SecureJoin when the untrusted path would resolve
+// outside of the root. It matches ErrEscapesRoot with errors.Is.
+type EscapeError struct {
+	Root string // Root directory the path must stay in
+	Path string // Untrusted path that escaped
+}
+
+func (e *EscapeError) Error() string {
+	return fmt.Sprintf("path %q escapes root %q", e.Path, e.Root)
+}
+
+func (e *EscapeError) Unwrap() error {
+	return ErrEscapesRoot
+}
+
+// SecureJoin joins untrusted path elements to the root directory, guaranteeing
+// that the result is inside root. Unlike JoinPath, it returns an *EscapeError
+// if the path would escape the root, either lexically (eg: "../../etc/passwd",
+// "/etc/passwd", "C:\Windows") or by following the symbolic links that exist
+// inside the root. Components are resolved in order, as the system would, so
+// ".." following a symbolic link refers to the parent of its target. Symbolic
+// links are resolved in the result, while missing components are joined
+// lexically.
+//
+// The check happens when the function is called, so a concurrent process
+// replacing a directory with a symbolic link can still redirect a later
+// operation on the result. Use Root to be safe against such races.
+func SecureJoin(root string, untrusted ...string) (string, error) {
+	unsafe := strings.Join(untrusted, PathSeparator)
+	for _, elem := range untrusted {
+		if IsAbsolutePath(elem) || filepath.VolumeName(elem) != "" || strings.HasPrefix(elem, PathSeparator) || strings.HasPrefix(elem, "/") {
+			return "", &EscapeError{Root: root, Path: unsafe}
+		}
+		if strings.IndexByte(elem, 0) >= 0 {
+			return "", newError("SecureJoin", unsafe, ErrInvalid)
+		}
+	}
+
+	// Absolute symlink targets are compared against the root as given and
+	// with its own links resolved, since targets usually use real paths.
+	root = filepath.Clean(root)
+	absRoots := []string{ForceAbsolutePath(root)}
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		absRoots = append(absRoots, ForceAbsolutePath(real))
+	}
+
+	// The components are resolved in order, without cleaning the path first,
+	// so a ".." following a symbolic link leaves the target of the link.
+	current := ""
+	remaining := []string{}
+	for _, elem := range untrusted {
+		remaining = append(remaining, splitPathComponents(elem)...)
+	}
+	links := 0
+	for len(remaining) > 0 {
+		part := remaining[0]
+		remaining = remaining[1:]
+
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			if current == "" {
+				return "", &EscapeError{Root: root, Path: unsafe}
+			}
+			current = filepath.Dir(current)
+			if current == "." {
+				current = ""
+			}
+			continue
+		}
+
+		next := filepath.Join(current, part)
+		info, err := os.Lstat(filepath.Join(root, next))
+		if err != nil && !os.IsNotExist(err) && !errors.Is(err, syscall.ENOTDIR) {
+			return "", newError("SecureJoin", filepath.Join(root, next), err)
+		}
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			current = next
+			continue
+		}
+
+		links++
+		if links > maxSymlinks {
+			return "", newError("SecureJoin", filepath.Join(root, next), syscall.ELOOP)
+		}
+		target, err := os.Readlink(filepath.Join(root, next))
+		if err != nil {
+			return "", newError("SecureJoin", filepath.Join(root, next), err)
+		}
+		if IsAbsolutePath(target) || filepath.VolumeName(target) != "" {
+			rel, ok := relativeToRoots(absRoots, target)
+			if !ok {
+				return "", &EscapeError{Root: root, Path: unsafe}
+			}
+			current = ""
+			target = rel
+		}
+		remaining = append(splitPathComponents(target), remaining...)
+	}
+	return filepath.Join(root, current), nil
+}
+
+// ForceSecureJoin is like SecureJoin but ignores any errors and returns an
+// empty string in such cases.
+func ForceSecureJoin(root string, untrusted ...string) string {
+	p, _ := SecureJoin(root, untrusted...)
+	return p
+}
+
+// splitPathComponents splits a path into its components, accepting both
+// forward slashes and the OS-specific separator.
+func splitPathComponents(p string) []string {
+	return strings.FieldsFunc(p, func(r rune) bool {
+		return r == '/' || r == os.PathSeparator
+	})
+}
+
+// relativeToRoots returns the target relative to the first root containing
+// it, or false if it is outside all of them.
+func relativeToRoots(roots []string, target string) (string, bool) {
+	for _, root := range roots {
+		rel, err := filepath.Rel(root, filepath.Clean(target))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+PathSeparator) {
+			continue
+		}
+		return rel, true
+	}
+	return "", false
+}
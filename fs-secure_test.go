@@ -0,0 +1,101 @@
+package fs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+// TestSecureJoin runs SecureJoin against a corpus of known traversal tricks.
+// The root contains:
+//
+//	dir/file
+//	sub/parent -> ..             (back to the root)
+//	sub/up -> ../..              (outside the root)
+//	sub/abs -> <root>/dir        (absolute, inside the root)
+//	sub/etc -> /etc              (absolute, outside the root)
+//	sub/chain -> parent/dir      (link through another link)
+//	sub/dangling -> ../../missing
+//	loop/a -> b, loop/b -> a
+func TestSecureJoin(t *testing.T) {
+	root := t.TempDir()
+	if err := CreateDir(filepath.Join(root, "dir")); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileString(filepath.Join(root, "dir", "file"), "x"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateDir(filepath.Join(root, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateDir(filepath.Join(root, "loop")); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{
+		"sub/parent":   "..",
+		"sub/up":       filepath.Join("..", ".."),
+		"sub/abs":      filepath.Join(root, "dir"),
+		"sub/etc":      "/etc",
+		"sub/chain":    filepath.Join("parent", "dir"),
+		"sub/dangling": filepath.Join("..", "..", "missing"),
+		"loop/a":       "b",
+		"loop/b":       "a",
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, filepath.FromSlash(link))); err != nil {
+			t.Skipf("symbolic links not supported: %v", err)
+		}
+	}
+
+	join := func(elem ...string) string {
+		return filepath.Join(append([]string{root}, elem...)...)
+	}
+	tests := []struct {
+		name      string
+		untrusted []string
+		want      string // Expected result, empty when an error is expected
+		wantErr   error
+	}{
+		{"plain", []string{"dir/file"}, join("dir", "file"), nil},
+		{"several elements", []string{"dir", "file"}, join("dir", "file"), nil},
+		{"empty", []string{""}, join(), nil},
+		{"dot", []string{"./dir/./file"}, join("dir", "file"), nil},
+		{"redundant separators", []string{"dir//file"}, join("dir", "file"), nil},
+		{"dot dot inside", []string{"dir/../dir/file"}, join("dir", "file"), nil},
+		{"missing components", []string{"missing/a/b"}, join("missing", "a", "b"), nil},
+		{"dot dot after missing", []string{"missing/../dir"}, join("dir"), nil},
+		{"three dots is a name", []string{"..."}, join("..."), nil},
+		{"dot dot prefix in name", []string{"..foo"}, join("..foo"), nil},
+		{"dot dot", []string{".."}, "", ErrEscapesRoot},
+		{"dot dot escape", []string{"../../etc/passwd"}, "", ErrEscapesRoot},
+		{"dot dot deep escape", []string{"dir/../../x"}, "", ErrEscapesRoot},
+		{"dot dot across elements", []string{"dir", "..", ".."}, "", ErrEscapesRoot},
+		{"absolute", []string{"/etc/passwd"}, "", ErrEscapesRoot},
+		{"absolute second element", []string{"dir", "/etc/passwd"}, "", ErrEscapesRoot},
+		{"nul byte", []string{"dir/file\x00.txt"}, "", ErrInvalid},
+		{"link to root", []string{"sub/parent/dir/file"}, join("dir", "file"), nil},
+		{"dot dot after link", []string{"sub/parent/../x"}, "", ErrEscapesRoot},
+		{"dot dot after link across elements", []string{"sub/parent", "..", "x"}, "", ErrEscapesRoot},
+		{"link escape", []string{"sub/up/x"}, "", ErrEscapesRoot},
+		{"absolute link inside", []string{"sub/abs/file"}, join("dir", "file"), nil},
+		{"absolute link outside", []string{"sub/etc/passwd"}, "", ErrEscapesRoot},
+		{"link chain", []string{"sub/chain/file"}, join("dir", "file"), nil},
+		{"dangling link escape", []string{"sub/dangling"}, "", ErrEscapesRoot},
+		{"link loop", []string{"loop/a"}, "", syscall.ELOOP},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := SecureJoin(root, tt.untrusted...)
+			switch {
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("SecureJoin(%q) = %q, %v, want error %v", tt.untrusted, got, err, tt.wantErr)
+				}
+			case err != nil || got != tt.want:
+				t.Fatalf("SecureJoin(%q) = %q, %v, want %q", tt.untrusted, got, err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,235 @@
+package fs
+
+import (
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Snapshot records the state of a directory tree at a point in time. It can be
+// saved to a file and later compared with the current state of the tree to
+// find out what changed in between, even if no watcher was running.
+type Snapshot struct {
+	Root    string                   `json:"root"`
+	Time    time.Time                `json:"time"`
+	Entries map[string]SnapshotEntry `json:"entries"`
+}
+
+// SnapshotEntry records the state of a single file or directory of a Snapshot.
+type SnapshotEntry struct {
+	Path    string      `json:"path"`            // Path relative to the root, using forward slashes
+	Size    int64       `json:"size"`            // Size in bytes
+	ModTime time.Time   `json:"mtime"`           // Modification time
+	Mode    os.FileMode `json:"mode"`            // File mode and permissions
+	Device  uint64      `json:"dev,omitempty"`   // Device number, if available
+	Inode   uint64      `json:"inode,omitempty"` // Inode number, if available
+	Hash    string      `json:"hash,omitempty"`  // Content hash, if requested
+}
+
+// Change describes a difference between two snapshots.
+type Change struct {
+	Op      fsnotify.Op // One of EvtCreate, EvtWrite, EvtRemove, EvtRename or EvtChmod
+	Path    string      // Path relative to the root, using forward slashes
+	OldPath string      // Previous path, for renames
+	IsDir   bool        // Whether the entry is a directory
+}
+
+func (c Change) String() string {
+	if c.Op.Has(EvtRename) {
+		return fmt.Sprintf("%s %s -> %s", c.Op, c.OldPath, c.Path)
+	}
+	return fmt.Sprintf("%s %s", c.Op, c.Path)
+}
+
+// SnapshotOption configures how a snapshot is taken.
+type SnapshotOption func(*snapshotOptions)
+
+type snapshotOptions struct {
+	newHash func() hash.Hash
+}
+
+// WithSnapshotHash hashes the content of every file using the given hash
+// constructor (eg: sha256.New), so content changes are detected even when the
+// size and modification time are preserved.
+func WithSnapshotHash(newHash func() hash.Hash) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.newHash = newHash
+	}
+}
+
+// TakeSnapshot records the current state of the directory tree rooted at the
+// specified path. If the path is not a directory or cannot be listed, it
+// returns an error.
+func TakeSnapshot(p string, opts ...SnapshotOption) (*Snapshot, error) {
+	options := snapshotOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	list, err := ListRecursive(p)
+	if err != nil {
+		return nil, newError("TakeSnapshot", p, err)
+	}
+
+	snapshot := &Snapshot{
+		Root:    p,
+		Time:    time.Now(),
+		Entries: make(map[string]SnapshotEntry, len(list)),
+	}
+	for _, rel := range list {
+		full := filepath.Join(p, rel)
+		info, err := GetInfo(full)
+		if err != nil {
+			return nil, newError("TakeSnapshot", full, err)
+		}
+
+		entry := SnapshotEntry{
+			Path:    ToSlashPath(rel),
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Mode:    info.Mode(),
+		}
+		entry.Device, entry.Inode, _ = getFileID(info)
+		if options.newHash != nil && !info.IsDir() {
+			entry.Hash, err = Hash(full, options.newHash())
+			if err != nil {
+				return nil, newError("TakeSnapshot", full, err)
+			}
+		}
+		snapshot.Entries[entry.Path] = entry
+	}
+	return snapshot, nil
+}
+
+// LoadSnapshot reads a snapshot saved with Snapshot.Save.
+func LoadSnapshot(p string) (*Snapshot, error) {
+	snapshot, err := ReadFileJsonAs[*Snapshot](p)
+	if err != nil {
+		return nil, newError("LoadSnapshot", p, err)
+	}
+	if snapshot == nil {
+		return nil, newError("LoadSnapshot", p, ErrInvalid)
+	}
+	if snapshot.Entries == nil {
+		snapshot.Entries = map[string]SnapshotEntry{}
+	}
+	return snapshot, nil
+}
+
+// Save writes the snapshot as JSON to a file at the specified path.
+func (s *Snapshot) Save(p string) error {
+	return newError("Save", p, WriteFileJson(p, s))
+}
+
+// Compare returns the changes needed to go from this snapshot to the given
+// one, sorted by path:
+//
+//   - Entries only present in the other snapshot are reported as EvtCreate.
+//   - Entries only present in this snapshot are reported as EvtRemove.
+//   - Files whose size, modification time, inode or hash changed are reported
+//     as EvtWrite. Directories are never reported as written.
+//   - Entries whose permissions changed are reported as EvtChmod.
+//   - A removed and a created entry are reported as a single EvtRename when
+//     they share the same device and inode or, when inodes are not available,
+//     the same hash.
+func (s *Snapshot) Compare(other *Snapshot) []Change {
+	changes := []Change{}
+	created := []SnapshotEntry{}
+	removed := []SnapshotEntry{}
+
+	for p, before := range s.Entries {
+		after, ok := other.Entries[p]
+		if !ok {
+			removed = append(removed, before)
+			continue
+		}
+
+		var op fsnotify.Op
+		if !after.Mode.IsDir() && isEntryWritten(before, after) {
+			op |= EvtWrite
+		}
+		if before.Mode.Perm() != after.Mode.Perm() {
+			op |= EvtChmod
+		}
+		if op != 0 {
+			changes = append(changes, Change{Op: op, Path: p, IsDir: after.Mode.IsDir()})
+		}
+	}
+	for p, after := range other.Entries {
+		if _, ok := s.Entries[p]; !ok {
+			created = append(created, after)
+		}
+	}
+
+	for _, before := range removed {
+		i := slices.IndexFunc(created, func(after SnapshotEntry) bool {
+			return isSameEntry(before, after)
+		})
+		if i < 0 {
+			changes = append(changes, Change{Op: EvtRemove, Path: before.Path, IsDir: before.Mode.IsDir()})
+			continue
+		}
+		after := created[i]
+		created = slices.Delete(created, i, i+1)
+		changes = append(changes, Change{Op: EvtRename, Path: after.Path, OldPath: before.Path, IsDir: after.Mode.IsDir()})
+	}
+	for _, after := range created {
+		changes = append(changes, Change{Op: EvtCreate, Path: after.Path, IsDir: after.Mode.IsDir()})
+	}
+
+	slices.SortFunc(changes, func(a, b Change) int {
+		if a.Path < b.Path {
+			return -1
+		}
+		if a.Path > b.Path {
+			return 1
+		}
+		return 0
+	})
+	return changes
+}
+
+// Changes takes a new snapshot of the same root and compares this snapshot
+// with it. It returns the changes along with the new snapshot, which can be
+// saved for the next comparison.
+func (s *Snapshot) Changes(opts ...SnapshotOption) ([]Change, *Snapshot, error) {
+	current, err := TakeSnapshot(s.Root, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.Compare(current), current, nil
+}
+
+// isEntryWritten reports whether the content of a file changed between two
+// entries with the same path.
+func isEntryWritten(before, after SnapshotEntry) bool {
+	if before.Size != after.Size || !before.ModTime.Equal(after.ModTime) {
+		return true
+	}
+	if before.Inode != 0 && after.Inode != 0 && (before.Inode != after.Inode || before.Device != after.Device) {
+		return true
+	}
+	return before.Hash != "" && after.Hash != "" && before.Hash != after.Hash
+}
+
+// isSameEntry reports whether two entries with different paths refer to the
+// same file, meaning it was renamed. Since inode numbers are reused once a
+// file is removed, files must also keep their size and modification time,
+// which a rename preserves.
+func isSameEntry(before, after SnapshotEntry) bool {
+	if before.Mode.IsDir() != after.Mode.IsDir() {
+		return false
+	}
+	if before.Inode != 0 && after.Inode != 0 {
+		if before.Inode != after.Inode || before.Device != after.Device {
+			return false
+		}
+		return before.Mode.IsDir() || (before.Size == after.Size && before.ModTime.Equal(after.ModTime))
+	}
+	return before.Hash != "" && before.Hash == after.Hash && before.Size == after.Size
+}
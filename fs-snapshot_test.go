@@ -0,0 +1,150 @@
+package fs
+
+import (
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"testing"
+	"time"
+)
+
+// changeStrings returns the String of every change.
+func changeStrings(changes []Change) []string {
+	list := make([]string, len(changes))
+	for i, change := range changes {
+		list[i] = change.String()
+	}
+	return list
+}
+
+func TestSnapshotChanges(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a": "a", "b": "b", "c": "c", "d": "d", "sub/e": "e"})
+	old, err := TakeSnapshot(dir, WithSnapshotHash(sha256.New))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := WriteFileString(filepath.Join(dir, "new"), "new"); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileString(filepath.Join(dir, "a"), "written"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Remove(filepath.Join(dir, "b")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Rename(filepath.Join(dir, "c"), filepath.Join(dir, "sub", "c")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Chmod(filepath.Join(dir, "d"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	changes, current, err := old.Changes(WithSnapshotHash(sha256.New))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"WRITE a", "REMOVE b", "CHMOD d", "CREATE new", "RENAME c -> sub/c"}
+	if runtime.GOOS == "windows" {
+		want = slices.DeleteFunc(want, func(s string) bool { return s == "CHMOD d" })
+	}
+	if got := changeStrings(changes); !slices.Equal(got, want) {
+		t.Errorf("Changes() = %q, want %q", got, want)
+	}
+	if changes := current.Compare(current); len(changes) != 0 {
+		t.Errorf("Compare() with itself = %q, want no changes", changeStrings(changes))
+	}
+}
+
+func TestSnapshotCompare(t *testing.T) {
+	mtime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	file := func(p string, size int64, inode uint64, hash string) SnapshotEntry {
+		return SnapshotEntry{Path: p, Size: size, ModTime: mtime, Mode: 0644, Device: 1, Inode: inode, Hash: hash}
+	}
+	dir := func(p string, inode uint64) SnapshotEntry {
+		return SnapshotEntry{Path: p, ModTime: mtime, Mode: os.ModeDir | 0755, Device: 1, Inode: inode}
+	}
+	snapshot := func(entries ...SnapshotEntry) *Snapshot {
+		s := &Snapshot{Entries: map[string]SnapshotEntry{}}
+		for _, entry := range entries {
+			s.Entries[entry.Path] = entry
+		}
+		return s
+	}
+	touched := file("a", 1, 0, "h")
+	touched.ModTime = mtime.Add(time.Second)
+	chmodded := file("a", 1, 0, "h")
+	chmodded.Mode = 0600
+
+	tests := []struct {
+		name          string
+		before, after *Snapshot
+		want          []string
+	}{
+		{"unchanged", snapshot(file("a", 1, 10, "h")), snapshot(file("a", 1, 10, "h")), []string{}},
+		{"create", snapshot(), snapshot(file("a", 1, 10, "")), []string{"CREATE a"}},
+		{"remove", snapshot(file("a", 1, 10, "")), snapshot(), []string{"REMOVE a"}},
+		{"write size", snapshot(file("a", 1, 10, "")), snapshot(file("a", 2, 10, "")), []string{"WRITE a"}},
+		{"write mtime", snapshot(file("a", 1, 0, "h")), snapshot(touched), []string{"WRITE a"}},
+		{"write hash", snapshot(file("a", 1, 0, "h1")), snapshot(file("a", 1, 0, "h2")), []string{"WRITE a"}},
+		{"write replaced inode", snapshot(file("a", 1, 10, "")), snapshot(file("a", 1, 11, "")), []string{"WRITE a"}},
+		{"chmod", snapshot(file("a", 1, 0, "h")), snapshot(chmodded), []string{"CHMOD a"}},
+		{"directory not written", snapshot(dir("d", 10)), snapshot(dir("d", 11)), []string{}},
+		{"rename by inode", snapshot(file("a", 1, 10, "")), snapshot(file("b", 1, 10, "")), []string{"RENAME a -> b"}},
+		{"rename directory by inode", snapshot(dir("d", 10)), snapshot(dir("e", 10)), []string{"RENAME d -> e"}},
+		{"reused inode", snapshot(file("a", 1, 10, "")), snapshot(file("b", 2, 10, "")), []string{"REMOVE a", "CREATE b"}},
+		{"rename by hash", snapshot(file("a", 1, 0, "h")), snapshot(file("b", 1, 0, "h")), []string{"RENAME a -> b"}},
+		{"different hash", snapshot(file("a", 1, 0, "h1")), snapshot(file("b", 1, 0, "h2")), []string{"REMOVE a", "CREATE b"}},
+		{"no inode nor hash", snapshot(file("a", 1, 0, "")), snapshot(file("b", 1, 0, "")), []string{"REMOVE a", "CREATE b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := changeStrings(tt.before.Compare(tt.after)); !slices.Equal(got, tt.want) {
+				t.Errorf("Compare() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	tree := filepath.Join(dir, "tree")
+	writeTree(t, tree, map[string]string{"a": "a", "sub/b": "b"})
+	snapshot, err := TakeSnapshot(tree, WithSnapshotHash(sha256.New))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := filepath.Join(dir, "snapshot.json")
+	if err := snapshot.Save(p); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadSnapshot(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Root != snapshot.Root || !loaded.Time.Equal(snapshot.Time) || len(loaded.Entries) != len(snapshot.Entries) {
+		t.Errorf("LoadSnapshot() = %+v, want %+v", loaded, snapshot)
+	}
+	for p, want := range snapshot.Entries {
+		got := loaded.Entries[p]
+		if got.Path != want.Path || got.Size != want.Size || !got.ModTime.Equal(want.ModTime) || got.Mode != want.Mode ||
+			got.Device != want.Device || got.Inode != want.Inode || got.Hash != want.Hash {
+			t.Errorf("loaded entry %s = %+v, want %+v", p, got, want)
+		}
+	}
+	if changes, _, err := loaded.Changes(WithSnapshotHash(sha256.New)); err != nil || len(changes) != 0 {
+		t.Errorf("Changes() after loading = %q, %v, want no changes", changeStrings(changes), err)
+	}
+
+	if err := WriteFileString(p, "null"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSnapshot(p); !errors.Is(err, ErrInvalid) {
+		t.Errorf("LoadSnapshot() of null error = %v, want ErrInvalid", err)
+	}
+}
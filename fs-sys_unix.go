@@ -0,0 +1,38 @@
+//go:build !windows
+
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+// getHiddenAttribute is only supported on Windows.
+func getHiddenAttribute(abs string) (bool, error) {
+	return false, ErrInvalid
+}
+
+// setHiddenAttribute is only supported on Windows.
+func setHiddenAttribute(abs string, hidden bool) error {
+	return ErrInvalid
+}
+
+// getFileID returns the device and inode numbers of the file described by
+// info, if available.
+func getFileID(info os.FileInfo) (dev uint64, ino uint64, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, 0, false
+	}
+	return uint64(stat.Dev), uint64(stat.Ino), true
+}
+
+// getFileOwner returns the user ID owning the file described by info, if
+// available.
+func getFileOwner(info os.FileInfo) (uid int, ok bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int(stat.Uid), true
+}
@@ -0,0 +1,52 @@
+package fs
+
+import (
+	"os"
+	"syscall"
+)
+
+// getHiddenAttribute reports whether the FILE_ATTRIBUTE_HIDDEN attribute is set
+// for the file at the specified absolute path.
+func getHiddenAttribute(abs string) (bool, error) {
+	pointer, err := syscall.UTF16PtrFromString(abs)
+	if err != nil {
+		return false, err
+	}
+	attributes, err := syscall.GetFileAttributes(pointer)
+	if err != nil {
+		return false, err
+	}
+	return attributes&syscall.FILE_ATTRIBUTE_HIDDEN != 0, nil
+}
+
+// setHiddenAttribute sets or clears the FILE_ATTRIBUTE_HIDDEN attribute of the
+// file at the specified absolute path.
+func setHiddenAttribute(abs string, hidden bool) error {
+	pointer, err := syscall.UTF16PtrFromString(abs)
+	if err != nil {
+		return err
+	}
+	attributes, err := syscall.GetFileAttributes(pointer)
+	if err != nil {
+		return err
+	}
+	if hidden {
+		attributes |= syscall.FILE_ATTRIBUTE_HIDDEN
+	} else {
+		attributes &^= syscall.FILE_ATTRIBUTE_HIDDEN
+	}
+	return syscall.SetFileAttributes(pointer, attributes)
+}
+
+// getFileID returns the device and inode numbers of the file described by
+// info. They are not available from a FileInfo on Windows, so it always
+// reports false.
+func getFileID(info os.FileInfo) (dev uint64, ino uint64, ok bool) {
+	return 0, 0, false
+}
+
+// getFileOwner returns the user ID owning the file described by info. Files
+// are not owned by user IDs on Windows, so it always reports false.
+func getFileOwner(info os.FileInfo) (uid int, ok bool) {
+	return 0, false
+}
@@ -0,0 +1,406 @@
+package fs
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// txJournal is the name of the journal file inside a transaction directory.
+const txJournal = "journal"
+
+// txEntry is a line of a transaction journal: either a path changed by the
+// transaction, with the backup of its prior state, a marker of a path restored
+// by a rollback, or the commit marker.
+type txEntry struct {
+	Path     string `json:"path,omitempty"`
+	Backup   string `json:"backup,omitempty"`
+	Link     string `json:"link,omitempty"`
+	Existed  bool   `json:"existed,omitempty"`
+	Restored bool   `json:"restored,omitempty"`
+	Commit   bool   `json:"commit,omitempty"`
+}
+
+// Tx is a transaction over several files and directories. Its operations are
+// applied immediately, after backing up every path they change, so Rollback
+// can restore the state before the transaction, and Commit discards the
+// backups:
+//
+//	tx, err := fs.BeginTx(".deploy-tx")
+//	if err != nil {
+//		return err
+//	}
+//	defer tx.Rollback()
+//	if err := tx.WriteFile("config.json", data); err != nil {
+//		return err
+//	}
+//	if err := tx.Move("release", "current"); err != nil {
+//		return err
+//	}
+//	return tx.Commit()
+//
+// The backups and a journal are kept in a directory of the journal directory
+// given to BeginTx, until the transaction ends. If the process dies before
+// that, RecoverTx rolls the transaction back on the next start.
+//
+// Backups are copies, so changing a large directory costs its size in the
+// journal directory. They keep the modes of the files and directories and
+// the symbolic links themselves, but not owners or times.
+type Tx struct {
+	mu      sync.Mutex
+	dir     string
+	journal *os.File
+	entries []txEntry
+	backups int
+	done    bool
+}
+
+// BeginTx starts a transaction, keeping its journal and backups in a new
+// directory inside journalDir, which is created if needed.
+func BeginTx(journalDir string) (*Tx, error) {
+	if err := os.MkdirAll(journalDir, 0700); err != nil {
+		return nil, newError("BeginTx", journalDir, err)
+	}
+	dir, err := os.MkdirTemp(journalDir, "tx-")
+	if err != nil {
+		return nil, newError("BeginTx", journalDir, err)
+	}
+	journal, err := os.OpenFile(filepath.Join(dir, txJournal), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, newError("BeginTx", journalDir, err)
+	}
+	return &Tx{dir: dir, journal: journal}, nil
+}
+
+// WriteFile is like WriteFileContext without a context, as part of the
+// transaction.
+func (tx *Tx) WriteFile(p string, data []byte, opts ...Option) error {
+	return newError("WriteFile", p, tx.apply([]string{p}, func() error {
+		return WriteFileContext(context.Background(), p, data, opts...)
+	}))
+}
+
+// Copy is like CopyContext without a context, as part of the transaction.
+func (tx *Tx) Copy(src, dst string, opts ...Option) error {
+	return newLinkError("Copy", src, dst, tx.apply([]string{dst}, func() error {
+		return CopyContext(context.Background(), src, dst, opts...)
+	}))
+}
+
+// Move is like MoveContext without a context, as part of the transaction.
+func (tx *Tx) Move(src, dst string, opts ...Option) error {
+	return newLinkError("Move", src, dst, tx.apply([]string{src, dst}, func() error {
+		return MoveContext(context.Background(), src, dst, opts...)
+	}))
+}
+
+// Remove is like RemoveContext without a context, as part of the transaction.
+func (tx *Tx) Remove(p string, opts ...Option) error {
+	return newError("Remove", p, tx.apply([]string{p}, func() error {
+		return RemoveContext(context.Background(), p, opts...)
+	}))
+}
+
+// Commit ends the transaction, keeping its changes and discarding the
+// backups. If the transaction already ended, it returns ErrTxDone.
+func (tx *Tx) Commit() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return newError("Commit", tx.dir, ErrTxDone)
+	}
+	if err := tx.log(txEntry{Commit: true}); err != nil {
+		return newError("Commit", tx.dir, err)
+	}
+	tx.done = true
+	tx.journal.Close()
+	return newError("Commit", tx.dir, removeTree(tx.dir))
+}
+
+// Rollback ends the transaction, restoring every path it changed to its state
+// before the transaction. If the transaction already ended, it returns
+// ErrTxDone, so it can be deferred right after BeginTx.
+//
+// If a path cannot be restored, the journal and backups are kept, so the
+// rollback can be retried with RecoverTx, which skips the paths already
+// restored.
+func (tx *Tx) Rollback() error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return newError("Rollback", tx.dir, ErrTxDone)
+	}
+	tx.done = true
+	err := tx.rollback()
+	tx.journal.Close()
+	if err != nil {
+		return newError("Rollback", tx.dir, err)
+	}
+	return newError("Rollback", tx.dir, removeTree(tx.dir))
+}
+
+// apply backs up the paths not yet backed up by the transaction and runs fn.
+func (tx *Tx) apply(paths []string, fn func() error) error {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	if tx.done {
+		return ErrTxDone
+	}
+	for _, p := range paths {
+		if err := tx.backup(p); err != nil {
+			return err
+		}
+	}
+	return fn()
+}
+
+// backup saves the state of the path and records it in the journal, unless
+// it was already saved.
+func (tx *Tx) backup(p string) error {
+	abs, err := AbsolutePath(p)
+	if err != nil {
+		return err
+	}
+	for _, entry := range tx.entries {
+		if entry.Path == abs {
+			return nil
+		}
+	}
+
+	entry := txEntry{Path: abs}
+	info, err := GetBackend().Lstat(abs)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil && info.Mode()&os.ModeSymlink != 0 {
+		entry.Existed = true
+		if entry.Link, err = GetBackend().Readlink(abs); err != nil {
+			return err
+		}
+	} else if err == nil {
+		entry.Existed = true
+		entry.Backup = filepath.Join(tx.dir, strconv.Itoa(tx.backups))
+		tx.backups++
+		if err := copyTree(abs, entry.Backup); err != nil {
+			removeTree(entry.Backup)
+			return err
+		}
+	}
+	if err := tx.log(entry); err != nil {
+		return err
+	}
+	tx.entries = append(tx.entries, entry)
+	return nil
+}
+
+// log appends the entry to the journal, flushing it to disk.
+func (tx *Tx) log(entry txEntry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.journal.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return tx.journal.Sync()
+}
+
+// rollback restores the paths of the journal entries, from the last one to
+// the first, so a path nested in another one backed up later ends up in its
+// state before the transaction.
+//
+// Each restored path is marked as such in the journal, so a retry skips it
+// instead of removing it again. Paths whose backup was already moved back,
+// when the process died before marking them, are skipped as well.
+func (tx *Tx) rollback() error {
+	restored := map[string]bool{}
+	for _, entry := range tx.entries {
+		if entry.Restored {
+			restored[entry.Path] = true
+		}
+	}
+	for i := len(tx.entries) - 1; i >= 0; i-- {
+		entry := tx.entries[i]
+		if entry.Path == "" || entry.Restored || restored[entry.Path] {
+			continue
+		}
+		if entry.Backup != "" {
+			if _, err := GetBackend().Lstat(entry.Backup); errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+		}
+		if err := restoreTxEntry(entry); err != nil {
+			return newError("Rollback", entry.Path, err)
+		}
+		if err := tx.log(txEntry{Path: entry.Path, Restored: true}); err != nil {
+			return newError("Rollback", entry.Path, err)
+		}
+	}
+	return nil
+}
+
+// restoreTxEntry restores the path of the entry to its state before the
+// transaction.
+func restoreTxEntry(entry txEntry) error {
+	if err := GetBackend().RemoveAll(entry.Path); err != nil {
+		return err
+	}
+	if !entry.Existed {
+		return nil
+	}
+	if err := GetBackend().MkdirAll(filepath.Dir(entry.Path), 0755); err != nil {
+		return err
+	}
+	if entry.Link != "" {
+		return GetBackend().Symlink(entry.Link, entry.Path)
+	}
+	if err := GetBackend().Rename(entry.Backup, entry.Path); err != nil {
+		return copyTree(entry.Backup, entry.Path)
+	}
+	return nil
+}
+
+// removeTree removes the path like RemoveAll, making its directories writable
+// first, as backups keep the modes of read-only directories.
+func removeTree(p string) error {
+	info, err := GetBackend().Lstat(p)
+	if err == nil && info.IsDir() {
+		GetBackend().Chmod(p, 0700)
+		entries, _ := GetBackend().ReadDir(p)
+		for _, entry := range entries {
+			if entry.IsDir() {
+				removeTree(filepath.Join(p, entry.Name()))
+			}
+		}
+	}
+	return GetBackend().RemoveAll(p)
+}
+
+// copyTree copies the file or directory at src to dst, recreating symbolic
+// links instead of following them and keeping the modes of the entries.
+func copyTree(src, dst string) error {
+	info, err := GetBackend().Lstat(src)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := GetBackend().Readlink(src)
+		if err != nil {
+			return err
+		}
+		return GetBackend().Symlink(target, dst)
+	case info.IsDir():
+		// The mode is set last, so a read-only directory can be filled.
+		if err := GetBackend().MkdirAll(dst, 0700); err != nil {
+			return err
+		}
+		entries, err := GetBackend().ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := copyTree(filepath.Join(src, entry.Name()), filepath.Join(dst, entry.Name())); err != nil {
+				return err
+			}
+		}
+	case info.Mode().IsRegular():
+		if err := copyFile(context.Background(), src, dst); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: cannot copy %s, of type %s", ErrInvalid, src, info.Mode().Type())
+	}
+	return GetBackend().Chmod(dst, info.Mode().Perm())
+}
+
+// RecoverTx finishes the transactions left in journalDir by a process that
+// died before committing or rolling them back. Transactions that were being
+// committed are committed, and the others rolled back. It does nothing if
+// journalDir does not exist.
+//
+// Call it on start, before beginning new transactions in the same directory.
+func RecoverTx(journalDir string) error {
+	entries, err := os.ReadDir(journalDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return newError("RecoverTx", journalDir, err)
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		dir := filepath.Join(journalDir, e.Name())
+		if err := recoverTx(dir); err != nil {
+			return newError("RecoverTx", dir, err)
+		}
+	}
+	return nil
+}
+
+// terminateJournal ends a journal whose last line was cut short by a crash
+// with a newline, so the entries appended by a recovery start on their own
+// line.
+func terminateJournal(f *os.File) error {
+	info, err := f.Stat()
+	if err != nil || info.Size() == 0 {
+		return err
+	}
+	last := make([]byte, 1)
+	if _, err := f.ReadAt(last, info.Size()-1); err != nil {
+		return err
+	}
+	if last[0] == '\n' {
+		return nil
+	}
+	_, err = f.Write([]byte{'\n'})
+	return err
+}
+
+// recoverTx finishes the transaction in the given directory.
+func recoverTx(dir string) error {
+	f, err := os.OpenFile(filepath.Join(dir, txJournal), os.O_APPEND|os.O_RDWR, 0600)
+	if errors.Is(err, os.ErrNotExist) {
+		return removeTree(dir)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	entries := []txEntry{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var entry txEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			// A line cut short by the crash, written before its change.
+			continue
+		}
+		if entry.Commit {
+			return removeTree(dir)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	if err := terminateJournal(f); err != nil {
+		return err
+	}
+	tx := &Tx{dir: dir, journal: f, entries: entries}
+	if err := tx.rollback(); err != nil {
+		return err
+	}
+	f.Close()
+	return removeTree(dir)
+}
@@ -0,0 +1,207 @@
+package fs
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// failingRollback writes a, b and c in a transaction and rolls it back with
+// the removal of b failing, so only c is restored. It returns the paths and
+// the directory of the transaction.
+func failingRollback(t *testing.T, journalDir string) (a, b, c, txDir string) {
+	t.Helper()
+	dir := filepath.Dir(journalDir)
+	a, b, c = filepath.Join(dir, "a"), filepath.Join(dir, "b"), filepath.Join(dir, "c")
+	for _, p := range []string{a, b, c} {
+		if err := WriteFileString(p, filepath.Base(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tx, err := BeginTx(journalDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{a, b, c} {
+		if err := tx.WriteFile(p, []byte("changed")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	faults := NewFaultBackend(OSBackend())
+	faults.FailOn(OpRemove, filepath.ToSlash(b), ErrPermission)
+	SetBackend(faults)
+	err = tx.Rollback()
+	SetBackend(nil)
+	if !errors.Is(err, ErrPermission) {
+		t.Fatalf("Rollback() error = %v, want ErrPermission", err)
+	}
+	checkContent(t, c, "c")
+	return a, b, c, tx.dir
+}
+
+func checkContent(t *testing.T, p, want string) {
+	t.Helper()
+	if got, err := ReadFileString(p); err != nil || got != want {
+		t.Errorf("ReadFileString(%s) = %q, %v, want %q", filepath.Base(p), got, err, want)
+	}
+}
+
+func TestTxRollbackRetry(t *testing.T) {
+	tests := []struct {
+		name  string
+		crash func(t *testing.T, journal string)
+	}{
+		{"restored markers", func(t *testing.T, journal string) {}},
+		{"crash before marking", func(t *testing.T, journal string) {
+			// c was moved back, but the process died before marking it.
+			lines := ForceReadFileLines(journal)
+			kept := []string{}
+			for _, line := range lines {
+				if !strings.Contains(line, `"restored"`) {
+					kept = append(kept, line)
+				}
+			}
+			if err := WriteFileLines(journal, kept); err != nil {
+				t.Fatal(err)
+			}
+		}},
+		{"line cut short", func(t *testing.T, journal string) {
+			if err := AppendFileString(journal, `{"path":"`); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journalDir := filepath.Join(t.TempDir(), "journal")
+			a, b, c, txDir := failingRollback(t, journalDir)
+			tt.crash(t, filepath.Join(txDir, txJournal))
+
+			if err := RecoverTx(journalDir); err != nil {
+				t.Fatalf("RecoverTx() = %v", err)
+			}
+			checkContent(t, a, "a")
+			checkContent(t, b, "b")
+			checkContent(t, c, "c")
+			if entries := ForceList(journalDir); len(entries) != 0 {
+				t.Errorf("journal directory = %v, want it empty", entries)
+			}
+		})
+	}
+}
+
+func TestTxRecoverAppendsAfterCutLine(t *testing.T) {
+	journalDir := filepath.Join(t.TempDir(), "journal")
+	a, b, _, txDir := failingRollback(t, journalDir)
+	journal := filepath.Join(txDir, txJournal)
+	if err := AppendFileString(journal, `{"path":"`); err != nil {
+		t.Fatal(err)
+	}
+
+	// b is restored and marked after the cut line, then a fails.
+	faults := NewFaultBackend(OSBackend())
+	faults.FailOn(OpRemove, filepath.ToSlash(a), ErrPermission)
+	SetBackend(faults)
+	err := RecoverTx(journalDir)
+	SetBackend(nil)
+	if !errors.Is(err, ErrPermission) {
+		t.Fatalf("RecoverTx() error = %v, want ErrPermission", err)
+	}
+	data := strings.TrimSuffix(ForceReadFileString(journal), "\n")
+	line := data[strings.LastIndexByte(data, '\n')+1:]
+	var last txEntry
+	if err := json.Unmarshal([]byte(line), &last); err != nil || !last.Restored || last.Path != ForceAbsolutePath(b) {
+		t.Errorf("last journal line = %q, want the restored marker of b", line)
+	}
+}
+
+func TestTxBackupKeepsSymlinks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symbolic links needs privileges on windows")
+	}
+	dir := t.TempDir()
+	tree := filepath.Join(dir, "tree")
+	if err := EnsureDir(filepath.Join(tree, "sub")); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFileString(filepath.Join(tree, "sub", "inner"), "inner"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Chmod(filepath.Join(tree, "sub", "inner"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Symlink("sub", filepath.Join(tree, "linkdir")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Symlink(filepath.Join("sub", "inner"), filepath.Join(tree, "linkfile")); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := BeginTx(filepath.Join(dir, "journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Remove(tree); err != nil {
+		t.Fatalf("Remove() = %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback() = %v", err)
+	}
+
+	for link, want := range map[string]string{"linkdir": "sub", "linkfile": filepath.Join("sub", "inner")} {
+		if got, err := Readlink(filepath.Join(tree, link)); err != nil || got != want {
+			t.Errorf("Readlink(%s) = %q, %v, want %q", link, got, err, want)
+		}
+	}
+	checkContent(t, filepath.Join(tree, "sub", "inner"), "inner")
+	if mode, err := GetMode(filepath.Join(tree, "sub", "inner")); err != nil || mode.Perm() != 0600 {
+		t.Errorf("GetMode(inner) = %v, %v, want 0600", mode, err)
+	}
+}
+
+func TestTxFailedBackupIsRemoved(t *testing.T) {
+	dir := t.TempDir()
+	tree := filepath.Join(dir, "tree")
+	other := filepath.Join(dir, "other")
+	if err := EnsureDir(tree); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{filepath.Join(tree, "a"), filepath.Join(tree, "b"), other} {
+		if err := WriteFileString(p, filepath.Base(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tx, err := BeginTx(filepath.Join(dir, "journal"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	faults := NewFaultBackend(OSBackend())
+	faults.Inject(Fault{Op: OpRead, Path: filepath.ToSlash(filepath.Join(tree, "b")), Err: ErrPermission})
+	SetBackend(faults)
+	err = tx.Remove(tree)
+	SetBackend(nil)
+	if !errors.Is(err, ErrPermission) {
+		t.Fatalf("Remove() error = %v, want ErrPermission", err)
+	}
+	if entries := ForceList(tx.dir); len(entries) != 1 || entries[0] != txJournal {
+		t.Errorf("transaction directory = %v, want only the journal", entries)
+	}
+
+	if err := tx.WriteFile(other, []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Remove(tree); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Rollback() = %v", err)
+	}
+	checkContent(t, other, "other")
+	checkContent(t, filepath.Join(tree, "a"), "a")
+	checkContent(t, filepath.Join(tree, "b"), "b")
+}
@@ -0,0 +1,107 @@
+package fs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// WaitPollInterval is the interval in which the WaitFor functions check the
+// path again, besides reacting to watcher events. It is also the only
+// mechanism used when the path cannot be watched.
+var WaitPollInterval = 250 * time.Millisecond
+
+// WaitForExists blocks until a file or directory exists at the specified path
+// or the context is done, in which case it returns an error wrapping the
+// context error.
+func WaitForExists(ctx context.Context, p string) error {
+	return newError("WaitForExists", p, waitFor(ctx, p, WaitPollInterval, func() bool {
+		return Exists(p)
+	}))
+}
+
+// WaitForRemoved blocks until nothing exists at the specified path or the
+// context is done, in which case it returns an error wrapping the context
+// error.
+func WaitForRemoved(ctx context.Context, p string) error {
+	return newError("WaitForRemoved", p, waitFor(ctx, p, WaitPollInterval, func() bool {
+		return !Exists(p)
+	}))
+}
+
+// WaitForStable blocks until the file at the specified path exists and its size
+// and modification time remain unchanged for the given duration, which is
+// useful to wait for a file being written by another process. If the context
+// is done first, it returns an error wrapping the context error.
+func WaitForStable(ctx context.Context, p string, d time.Duration) error {
+	var last os.FileInfo
+	var since time.Time
+	return newError("WaitForStable", p, waitFor(ctx, p, min(WaitPollInterval, d/2), func() bool {
+		info, err := os.Stat(p)
+		if err != nil {
+			last = nil
+			return false
+		}
+		if last == nil || info.Size() != last.Size() || !info.ModTime().Equal(last.ModTime()) {
+			last = info
+			since = time.Now()
+		}
+		return time.Since(since) >= d
+	}))
+}
+
+// WaitForContent blocks until the file at the specified path exists and its
+// content satisfies the predicate. If the context is done first, it returns
+// an error wrapping the context error.
+func WaitForContent(ctx context.Context, p string, predicate func(data []byte) bool) error {
+	return newError("WaitForContent", p, waitFor(ctx, p, WaitPollInterval, func() bool {
+		data, err := ReadFile(p)
+		return err == nil && predicate(data)
+	}))
+}
+
+// waitFor blocks until check returns true or the context is done. The check
+// runs whenever the watcher reports an event for the path, and on every tick
+// of the given interval.
+//
+// The watch is registered before the first check, so a change happening in
+// between is not missed. If the parent directory cannot be watched, it falls
+// back to polling only.
+func waitFor(ctx context.Context, p string, interval time.Duration, check func() bool) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	changed := make(chan struct{}, 1)
+	w, err := NewWatcher()
+	if err == nil {
+		defer w.Close()
+		err = w.Add(filepath.Dir(filepath.Clean(p)))
+	}
+	if err == nil {
+		go w.watch(ctx, fileFilter(p), func(event Event) {
+			select {
+			case changed <- struct{}{}:
+			default:
+			}
+		})
+	}
+
+	if interval <= 0 {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if check() {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
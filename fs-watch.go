@@ -2,50 +2,99 @@ package fs
 
 import (
 	"context"
+	"errors"
+	"os"
+	"path/filepath"
 	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
-// Watcher represents a file system watcher.
+// Watcher represents a file system watcher. It is safe for concurrent use, so
+// paths can be added, removed and inspected while events are being delivered.
 type Watcher struct {
 	watcher *fsnotify.Watcher
-	files   []string
+
+	mu      sync.RWMutex
+	entries map[string]watchEntry
+
+	delivered atomic.Int64
+	filtered  atomic.Int64
+	overflows atomic.Int64
+	errors    atomic.Int64
+}
+
+// watchEntry records the root a watched path was registered under and whether
+// it was a directory at that time.
+type watchEntry struct {
+	root string
+	dir  bool
+}
+
+// WatcherStats holds counters describing the activity of a Watcher.
+type WatcherStats struct {
+	Watches   int   // Number of paths being watched
+	Delivered int64 // Number of events delivered to the callback
+	Filtered  int64 // Number of events discarded by filters, such as the one of WatchFile
+	Overflows int64 // Number of times the system event queue overflowed, each losing an unknown number of events
+	Errors    int64 // Number of error events delivered to the callback, overflows included
 }
 
 // NewWatcher creates a new file system watcher.
 func NewWatcher() (*Watcher, error) {
 	w, err := fsnotify.NewWatcher()
 	if err != nil {
-		return nil, err
+		return nil, newError("NewWatcher", "", err)
 	}
 
 	return &Watcher{
 		watcher: w,
-		files:   []string{},
+		entries: map[string]watchEntry{},
 	}, nil
 }
 
 // Watch starts watching for file system events and invokes the provided
 // callback function for each event.
 func (w *Watcher) Watch(ctx context.Context, callback func(event Event)) error {
+	return newError("Watch", "", w.watch(ctx, nil, callback))
+}
+
+// watch is like Watch, but events are passed to filter before being
+// delivered. Events for which filter returns false are discarded and counted
+// as filtered. The filter may modify the event.
+func (w *Watcher) watch(ctx context.Context, filter func(event *Event) bool, callback func(event Event)) error {
 	for {
 		select {
-		case event, ok := <-w.watcher.Events:
+		case e, ok := <-w.watcher.Events:
 			if !ok {
 				return nil
 			}
-			callback(Event{
-				Op:   event.Op,
-				Path: event.Name,
-			})
+			event := w.newEvent(e.Op, e.Name)
+			if event.Has(EvtRemove) {
+				w.forget(e.Name)
+			}
+			if filter != nil && !filter(&event) {
+				w.filtered.Add(1)
+				continue
+			}
+			w.delivered.Add(1)
+			callback(event)
 		case err, ok := <-w.watcher.Errors:
 			if !ok {
 				return err
 			}
+			if errors.Is(err, fsnotify.ErrEventOverflow) {
+				w.overflows.Add(1)
+			}
+			w.errors.Add(1)
+			w.delivered.Add(1)
 			callback(Event{
-				Op:  EvtError,
-				Err: err,
+				Op:   EvtError,
+				Err:  err,
+				Time: time.Now(),
 			})
 		case <-ctx.Done():
 			return nil
@@ -53,42 +102,113 @@ func (w *Watcher) Watch(ctx context.Context, callback func(event Event)) error {
 	}
 }
 
-// Add adds a path to the watcher.
+// newEvent builds the event delivered to callbacks, taking a stat snapshot of
+// the path and resolving the root it was registered under.
+func (w *Watcher) newEvent(op fsnotify.Op, p string) Event {
+	event := Event{
+		Op:   op,
+		Path: p,
+		Time: time.Now(),
+	}
+
+	w.mu.RLock()
+	entry, ok := w.entries[filepath.Clean(p)]
+	if !ok {
+		entry, ok = w.entries[filepath.Dir(p)]
+		entry.dir = false
+	}
+	w.mu.RUnlock()
+	if ok {
+		event.Root = entry.root
+		event.Rel, _ = filepath.Rel(entry.root, p)
+	}
+
+	info, err := os.Stat(p)
+	if err == nil {
+		event.Info = info
+		event.IsDir = info.IsDir()
+	} else {
+		event.IsDir = entry.dir
+	}
+	return event
+}
+
+// Add adds a path to the watcher. Events for the path, or for entries inside
+// it when it is a directory, report it as their root.
 func (w *Watcher) Add(p string) error {
+	return newError("Add", p, w.add(p, p))
+}
+
+// add adds a path to the watcher, registering it under the given root.
+func (w *Watcher) add(p string, root string) error {
 	err := w.watcher.Add(p)
-	if err == nil {
-		w.files = append(w.files, p)
+	if err != nil {
+		return err
 	}
-	return err
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.entries[filepath.Clean(p)] = watchEntry{
+		root: filepath.Clean(root),
+		dir:  IsDir(p),
+	}
+	return nil
 }
 
 // Has checks if a path is being watched.
-func (w *Watcher) Has(path string) bool {
-	return slices.Contains(w.files, path)
+func (w *Watcher) Has(p string) bool {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	_, ok := w.entries[filepath.Clean(p)]
+	return ok
 }
 
-// Remove removes a path from the watcher.
+// Remove removes a path from the watcher. Paths that were already dropped by
+// the system, for example because they were removed, are forgotten as well.
 func (w *Watcher) Remove(p string) error {
 	err := w.watcher.Remove(p)
-	if err == nil {
-		for i, file := range w.files {
-			if file == p {
-				w.files = append(w.files[:i], w.files[i+1:]...)
-				break
-			}
-		}
+	if err == nil || errors.Is(err, fsnotify.ErrNonExistentWatch) {
+		w.forget(p)
 	}
-	return err
+	return newError("Remove", p, err)
 }
 
-// WatchList returns the list of paths being watched.
+// forget removes a path from the bookkeeping of the watcher.
+func (w *Watcher) forget(p string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.entries, filepath.Clean(p))
+}
+
+// WatchList returns the sorted list of paths being watched.
 func (w *Watcher) WatchList() []string {
-	return w.watcher.WatchList()
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	list := make([]string, 0, len(w.entries))
+	for p := range w.entries {
+		list = append(list, p)
+	}
+	slices.Sort(list)
+	return list
+}
+
+// Stats returns the counters of the watcher.
+func (w *Watcher) Stats() WatcherStats {
+	w.mu.RLock()
+	watches := len(w.entries)
+	w.mu.RUnlock()
+	return WatcherStats{
+		Watches:   watches,
+		Delivered: w.delivered.Load(),
+		Filtered:  w.filtered.Load(),
+		Overflows: w.overflows.Load(),
+		Errors:    w.errors.Load(),
+	}
 }
 
 // Close closes the watcher.
 func (w *Watcher) Close() error {
-	return w.watcher.Close()
+	return newError("Close", "", w.watcher.Close())
 }
 
 // Watch watches a path for file system events and invokes the provided
@@ -96,44 +216,126 @@ func (w *Watcher) Close() error {
 func Watch(ctx context.Context, p string, callback func(event Event)) error {
 	w, err := NewWatcher()
 	if err != nil {
-		return err
+		return newError("Watch", p, err)
+	}
+	defer w.Close()
+
+	err = w.Add(p)
+	if err != nil {
+		return newError("Watch", p, err)
+	}
+	return newError("Watch", p, w.watch(ctx, nil, callback))
+}
+
+// WatchFile watches a single file for file system events and invokes the
+// provided callback function for each event affecting it.
+//
+// Instead of watching the file itself, it watches the parent directory and
+// filters events by name. This way the file keeps being followed when it is
+// replaced by a rename (as many editors do on save), removed or recreated.
+// Since a replacement shows up as the file being created again, creation
+// events are also reported with EvtWrite, so callbacks interested in content
+// changes only need to check for EvtWrite.
+func WatchFile(ctx context.Context, p string, callback func(event Event)) error {
+	w, err := NewWatcher()
+	if err != nil {
+		return newError("WatchFile", p, err)
 	}
-	w.Add(p)
-	return w.Watch(ctx, callback)
+	defer w.Close()
+
+	err = w.Add(filepath.Dir(filepath.Clean(p)))
+	if err != nil {
+		return newError("WatchFile", p, err)
+	}
+	return newError("WatchFile", p, w.watch(ctx, fileFilter(p), callback))
+}
+
+// fileFilter returns a filter accepting only the events of the file at p when
+// watching its parent directory. Creation events are also reported as writes.
+func fileFilter(p string) func(event *Event) bool {
+	target := filepath.Clean(p)
+	return func(event *Event) bool {
+		if filepath.Clean(event.Path) != target {
+			return false
+		}
+		if event.Has(EvtCreate) {
+			event.Op |= EvtWrite
+		}
+		return true
+	}
+}
+
+// addRecursive adds a directory and all its subdirectories to the watcher,
+// registering them under the given root. Directories excluded by the matcher
+// are skipped along with their contents, as are subdirectories that cannot be
+// read or watched.
+func (w *Watcher) addRecursive(p string, root string, ignore *ignoreMatcher) error {
+	return filepath.WalkDir(p, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			return nil
+		}
+		if err == nil && ignore.match(ForceRelativePath(root, path), true) {
+			return filepath.SkipDir
+		}
+		if err == nil {
+			ignore.load(path)
+			err = w.add(path, root)
+		}
+		if err != nil && path == p {
+			return err
+		}
+		if err != nil {
+			return filepath.SkipDir
+		}
+		return nil
+	})
 }
 
 // WatchRecursive watches a path and all its subdirectories for file system
-// events and invokes the provided callback function for each event.
-func WatchRecursive(ctx context.Context, p string, callback func(event Event)) error {
+// events and invokes the provided callback function for each event. Options
+// can be provided to exclude paths from being watched, see WithExclude and
+// WithGitIgnore.
+func WatchRecursive(ctx context.Context, p string, callback func(event Event), opts ...WatchOption) error {
+	return newError("WatchRecursive", p, watchRecursive(ctx, p, nil, callback, opts))
+}
+
+// watchRecursive implements WatchRecursive, discarding the events rejected by
+// the filter after the watches have been updated.
+func watchRecursive(ctx context.Context, p string, filter func(event *Event) bool, callback func(event Event), opts []WatchOption) error {
 	w, err := NewWatcher()
 	if err != nil {
 		return err
 	}
-	w.Add(p)
-	return w.Watch(ctx, func(event Event) {
-		if event.Has(EvtCreate) && IsDir(event.Path) {
-			w.Add(event.Path)
+	defer w.Close()
+
+	ignore := newIgnoreMatcher(p, opts)
+	err = w.addRecursive(p, p, ignore)
+	if err != nil {
+		return err
+	}
+	return w.watch(ctx, func(event *Event) bool {
+		if ignore.match(event.Rel, event.IsDir) {
+			return false
 		}
-		if event.Has(EvtRemove) && IsDir(event.Path) {
-			w.Remove(event.Path)
+		if event.Has(EvtCreate) && event.IsDir {
+			w.addRecursive(event.Path, event.Root, ignore)
 		}
-		if event.Has(EvtRename) && IsDir(event.Path) {
+		if event.Has(EvtRename) && event.IsDir {
 			w.Remove(event.Path)
 		}
-		callback(event)
-	})
+		return filter == nil || filter(event)
+	}, callback)
 }
 
 // WatchGlob watches a directory for file system events matching a glob pattern
-// and invokes the provided callback function for each matching event.
-func WatchGlob(ctx context.Context, dir string, pattern string, callback func(event Event)) error {
+// and invokes the provided callback function for each matching event. It
+// accepts the same options as WatchRecursive.
+func WatchGlob(ctx context.Context, dir string, pattern string, callback func(event Event), opts ...WatchOption) error {
 	if !IsPatternValid(pattern) {
-		return ErrInvalid
+		return newError("WatchGlob", pattern, ErrInvalid)
 	}
 
-	return WatchRecursive(ctx, dir, func(event Event) {
-		if ForceMatch(ToSlashPath(event.Path), JoinPathLinux(dir, pattern)) {
-			callback(event)
-		}
-	})
+	return newError("WatchGlob", dir, watchRecursive(ctx, dir, func(event *Event) bool {
+		return ForceMatch(ToSlashPath(event.Rel), pattern)
+	}, callback, opts))
 }
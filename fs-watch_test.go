@@ -0,0 +1,165 @@
+package fs
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// TestWatcherConcurrentBookkeeping adds, removes and inspects paths while
+// events are being delivered. Run it with -race.
+func TestWatcherConcurrentBookkeeping(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	delivered := make(chan struct{}, 1)
+	watchDone := make(chan error, 1)
+	go func() {
+		watchDone <- w.Watch(ctx, func(event Event) {
+			w.Has(event.Path)
+			w.WatchList()
+			w.Stats()
+			select {
+			case delivered <- struct{}{}:
+			default:
+			}
+		})
+	}()
+
+	var wg sync.WaitGroup
+	for i := range 4 {
+		sub := filepath.Join(dir, "sub"+strconv.Itoa(i))
+		if err := CreateDir(sub); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := range 500 {
+				WriteFileString(filepath.Join(dir, "file"+strconv.Itoa(i)), strconv.Itoa(j))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for range 500 {
+				w.Add(sub)
+				w.Has(sub)
+				w.WatchList()
+				w.Stats()
+				w.Remove(sub)
+			}
+		}()
+	}
+	wg.Wait()
+
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event delivered")
+	}
+	cancel()
+	if err := <-watchDone; err != nil {
+		t.Fatal(err)
+	}
+
+	stats := w.Stats()
+	if stats.Delivered == 0 {
+		t.Errorf("Stats().Delivered = 0, want > 0")
+	}
+	if stats.Watches != 1 || !w.Has(dir) {
+		t.Errorf("WatchList() = %v, want [%s]", w.WatchList(), dir)
+	}
+}
+
+func TestWatcherStatsFilteredAndOverflows(t *testing.T) {
+	dir := t.TempDir()
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.Add(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := make(chan Event, 16)
+	watchDone := make(chan error, 1)
+	filter := func(event *Event) bool { return filepath.Base(event.Path) != "ignored" }
+	go func() {
+		watchDone <- w.watch(ctx, filter, func(event Event) { events <- event })
+	}()
+
+	// Events are delivered in order, so once kept arrives, ignored was filtered.
+	WriteFileString(filepath.Join(dir, "ignored"), "data")
+	WriteFileString(filepath.Join(dir, "kept"), "data")
+	w.watcher.Errors <- fsnotify.ErrEventOverflow
+	gotKept, gotOverflow := false, false
+	for !gotKept || !gotOverflow {
+		select {
+		case event := <-events:
+			gotKept = gotKept || filepath.Base(event.Path) == "kept"
+			gotOverflow = gotOverflow || errors.Is(event.Err, fsnotify.ErrEventOverflow)
+		case <-time.After(5 * time.Second):
+			t.Fatal("events not delivered")
+		}
+	}
+	cancel()
+	if err := <-watchDone; err != nil {
+		t.Fatal(err)
+	}
+
+	stats := w.Stats()
+	if stats.Filtered == 0 || stats.Overflows != 1 || stats.Errors != 1 {
+		t.Errorf("Stats() = %+v, want Filtered > 0, Overflows = 1 and Errors = 1", stats)
+	}
+}
+
+func TestWatchRecursiveSkipsExcludedDirectories(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]string{
+		".gitignore":             "build/\n",
+		".git/HEAD":              "",
+		"build/out/a":            "",
+		"node_modules/pkg/index": "",
+		"src/main":               "",
+		"src/.gitignore":         "gen/\n",
+		"src/gen/code":           "",
+		"src/lib/node_modules/x": "",
+		"src/lib/util":           "",
+	})
+	w, err := NewWatcher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	ignore := newIgnoreMatcher(root, []WatchOption{WithExclude("node_modules"), WithGitIgnore()})
+	if err := w.addRecursive(root, root, ignore); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{root, filepath.Join(root, "src"), filepath.Join(root, "src", "lib")}
+	if got := w.WatchList(); !slices.Equal(got, want) {
+		t.Errorf("WatchList() = %v, want %v", got, want)
+	}
+	if stats := w.Stats(); stats.Watches != len(want) {
+		t.Errorf("Stats().Watches = %d, want %d", stats.Watches, len(want))
+	}
+}
@@ -0,0 +1,171 @@
+package fs
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// defaultPathExt is used on Windows when PATHEXT is not set.
+const defaultPathExt = ".com;.exe;.bat;.cmd"
+
+// Which returns the path of the executable with the given name, searching the
+// directories in the PATH environment variable in order. If the name contains
+// a path separator, it is checked directly instead. Only files the current
+// user can execute are considered, and directories that cannot be read are
+// skipped. If no executable is found, it returns an error wrapping ErrNotExist.
+//
+// On Windows, names without an extension are also tried with each extension
+// in the PATHEXT environment variable.
+func Which(name string) (string, error) {
+	p, err := WhichEnv(name, os.Environ())
+	return p, newError("Which", name, err)
+}
+
+// ForceWhich is like Which but ignores any errors and returns an empty string
+// in such cases.
+func ForceWhich(name string) string {
+	p, _ := Which(name)
+	return p
+}
+
+// WhichAll is like Which, but returns every matching executable in the order
+// of the PATH directories. If none is found, it returns an error
+// wrapping ErrNotExist.
+func WhichAll(name string) ([]string, error) {
+	matches, err := WhichAllEnv(name, os.Environ())
+	return matches, newError("WhichAll", name, err)
+}
+
+// WhichEnv is like Which, but reads PATH and PATHEXT from the given
+// environment, in the "key=value" form returned by os.Environ.
+func WhichEnv(name string, env []string) (string, error) {
+	matches := whichEnv(name, env, false)
+	if len(matches) == 0 {
+		return "", newError("WhichEnv", name, ErrNotExist)
+	}
+	return matches[0], nil
+}
+
+// WhichAllEnv is like WhichAll, but reads PATH and PATHEXT from the given
+// environment, in the "key=value" form returned by os.Environ.
+func WhichAllEnv(name string, env []string) ([]string, error) {
+	matches := whichEnv(name, env, true)
+	if len(matches) == 0 {
+		return []string{}, newError("WhichAllEnv", name, ErrNotExist)
+	}
+	return matches, nil
+}
+
+// whichEnv implements the Which functions, returning the first match or all
+// of them.
+func whichEnv(name string, env []string, all bool) []string {
+	if name == "" {
+		return nil
+	}
+
+	pathExt := ""
+	if runtime.GOOS == "windows" {
+		pathExt = getPathExt(env)
+	}
+
+	if strings.ContainsAny(name, "/"+PathSeparator) {
+		return findExecutables(name, pathExt, false)
+	}
+
+	matches := []string{}
+	for _, dir := range filepath.SplitList(lookupEnv(env, "PATH")) {
+		if dir == "" {
+			continue
+		}
+		for _, p := range findExecutables(filepath.Join(dir, name), pathExt, all) {
+			if !slices.Contains(matches, p) {
+				matches = append(matches, p)
+			}
+			if !all {
+				return matches
+			}
+		}
+	}
+	return matches
+}
+
+// findExecutables returns the executables for the given path, trying the
+// extensions in pathExt when it has none of them.
+func findExecutables(p string, pathExt string, all bool) []string {
+	candidates := []string{p}
+	if pathExt != "" && !hasExecutableExtension(p, pathExt) {
+		candidates = []string{}
+		for _, ext := range filepath.SplitList(pathExt) {
+			if ext != "" {
+				candidates = append(candidates, p+strings.ToLower(ext))
+			}
+		}
+	}
+
+	matches := []string{}
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() || !isExecutable(candidate, pathExt) {
+			continue
+		}
+		matches = append(matches, candidate)
+		if !all {
+			break
+		}
+	}
+	return matches
+}
+
+// isExecutable reports whether the current user can execute the file. On
+// Windows, it has to have one of the extensions in pathExt instead, so an
+// environment given to WhichEnv is honored.
+func isExecutable(p string, pathExt string) bool {
+	if runtime.GOOS == "windows" {
+		return hasExecutableExtension(p, pathExt)
+	}
+	return Access(p, AccessExecute) == nil
+}
+
+// getPathExt returns the PATHEXT variable of the given environment, or
+// defaultPathExt if it is not set.
+func getPathExt(env []string) string {
+	if pathExt := lookupEnv(env, "PATHEXT"); pathExt != "" {
+		return pathExt
+	}
+	return defaultPathExt
+}
+
+// hasExecutableExtension reports whether the path has one of the extensions
+// in pathExt, a list like %PATHEXT%.
+func hasExecutableExtension(p string, pathExt string) bool {
+	ext := strings.ToLower(filepath.Ext(p))
+	if ext == "" {
+		return false
+	}
+	for _, e := range filepath.SplitList(pathExt) {
+		if strings.ToLower(e) == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupEnv returns the value of the variable in the given environment. On
+// Windows, variable names are case-insensitive. If the variable is defined
+// more than once, the last value wins, as in os/exec.
+func lookupEnv(env []string, key string) string {
+	value := ""
+	for _, kv := range env {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		if k == key || (runtime.GOOS == "windows" && strings.EqualFold(k, key)) {
+			value = v
+		}
+	}
+	return value
+}
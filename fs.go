@@ -6,28 +6,43 @@ import (
 	"errors"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
 
 var (
-	ErrIsDir            = errors.New("is a directory")
-	ErrNotDir           = errors.New("not a directory")
-	ErrIsFile           = errors.New("is a file")
-	ErrNotFile          = errors.New("not a file")
-	ErrInvalid          = os.ErrInvalid
-	ErrPermission       = os.ErrPermission
-	ErrExist            = os.ErrExist
-	ErrNotExist         = os.ErrNotExist
-	ErrClosed           = os.ErrClosed
-	ErrNoDeadline       = os.ErrNoDeadline
-	ErrDeadlineExceeded = os.ErrDeadlineExceeded
+	ErrIsDir             = errors.New("is a directory")
+	ErrNotDir            = errors.New("not a directory")
+	ErrIsFile            = errors.New("is a file")
+	ErrNotFile           = errors.New("not a file")
+	ErrEscapesRoot       = errors.New("path escapes root")
+	ErrUndefinedVariable = errors.New("undefined variable")
+	ErrCrashed           = errors.New("simulated crash")
+	ErrTampered          = errors.New("audit log tampered")
+	ErrTxDone            = errors.New("transaction already committed or rolled back")
+	ErrInvalid           = os.ErrInvalid
+	ErrPermission        = os.ErrPermission
+	ErrExist             = os.ErrExist
+	ErrNotExist          = os.ErrNotExist
+	ErrClosed            = os.ErrClosed
+	ErrNoDeadline        = os.ErrNoDeadline
+	ErrDeadlineExceeded  = os.ErrDeadlineExceeded
 )
 
+// Event describes a file system change reported by a Watcher. Besides the
+// operation and the path, it carries a snapshot of the path taken when the
+// event was delivered, so callbacks do not need to stat the path again.
 type Event struct {
 	Op   fsnotify.Op
 	Path string
 	Err  error
+
+	Root  string      // Watched path the event was registered under (eg: sample)
+	Rel   string      // Path relative to Root (eg: dir/file.txt)
+	Info  os.FileInfo // Stat snapshot taken at delivery, nil if it failed
+	IsDir bool        // Whether the path was a directory at delivery
+	Time  time.Time   // Delivery time, carrying a monotonic clock reading
 }
 
 func (e Event) Has(op fsnotify.Op) bool {